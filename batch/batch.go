@@ -0,0 +1,240 @@
+// Package batch groups concurrent calls into batches.
+//
+// Callers submit one item each with Add and wait for their own result,
+// while the Batcher runs a single call for the whole batch. A batch is
+// flushed when it is full, when it has waited its maximum delay, or early
+// enough to meet the tightest deadline among its callers. A caller whose
+// context ends before its batch is flushed is taken out of the batch.
+package batch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrClosed is returned by Add after Close.
+var ErrClosed = errors.New("batch: batcher closed")
+
+// Func processes a batch of items and returns one result per item, in the
+// same order.
+type Func[T, R any] func(ctx context.Context, items []T) ([]R, error)
+
+type options struct {
+	size   int
+	delay  time.Duration
+	margin time.Duration
+}
+
+// Option configures a Batcher.
+type Option func(*options)
+
+// MaxSize sets the number of items at which a batch is flushed. The
+// default is 100.
+func MaxSize(n int) Option {
+	return func(o *options) { o.size = max(n, 1) }
+}
+
+// MaxDelay sets how long the first item of a batch waits for others before
+// the batch is flushed. The default is 10ms.
+func MaxDelay(d time.Duration) Option {
+	return func(o *options) { o.delay = d }
+}
+
+// Margin sets how long before the earliest caller deadline a batch is
+// flushed, which should cover the time the Func takes. The default is 5ms.
+func Margin(d time.Duration) Option {
+	return func(o *options) { o.margin = d }
+}
+
+// Batcher collects items into batches for a Func. It is safe for
+// concurrent use.
+type Batcher[T, R any] struct {
+	fn   Func[T, R]
+	opts options
+
+	mu     sync.Mutex
+	cur    *pending[T, R]
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// call is one caller's item.
+type call[T, R any] struct {
+	item T
+	done chan struct{}
+	res  R
+	err  error
+}
+
+// pending is a batch that has not been flushed yet.
+type pending[T, R any] struct {
+	calls   []*call[T, R]
+	flushAt time.Time
+	timer   *time.Timer
+	flushed bool
+	// latest is the latest deadline among the callers, or zero if any of
+	// them has none.
+	latest time.Time
+}
+
+// New returns a Batcher that runs fn on batches of items.
+func New[T, R any](fn Func[T, R], opts ...Option) *Batcher[T, R] {
+	o := options{size: 100, delay: 10 * time.Millisecond, margin: 5 * time.Millisecond}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &Batcher[T, R]{fn: fn, opts: o}
+}
+
+// Add adds item to the current batch and waits for its result. If ctx ends
+// first, Add returns its cause, and the item is left out of the batch
+// unless the batch has already been flushed.
+//
+// The Func runs under a context that carries the latest deadline of the
+// batch's callers, if they all have one.
+func (b *Batcher[T, R]) Add(ctx context.Context, item T) (R, error) {
+	var zero R
+	if ctx.Err() != nil {
+		return zero, context.Cause(ctx)
+	}
+	c := &call[T, R]{item: item, done: make(chan struct{})}
+
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return zero, ErrClosed
+	}
+	p := b.cur
+	if p == nil {
+		p = &pending[T, R]{flushAt: time.Now().Add(b.opts.delay)}
+		p.timer = time.AfterFunc(b.opts.delay, func() { b.flush(p) })
+		b.cur = p
+	}
+	deadline, ok := ctx.Deadline()
+	switch {
+	case !ok:
+		p.latest = time.Time{}
+	case len(p.calls) == 0 || !p.latest.IsZero() && deadline.After(p.latest):
+		p.latest = deadline
+	}
+	p.calls = append(p.calls, c)
+	if len(p.calls) >= b.opts.size {
+		// Detach the full batch before unlocking, so that later calls
+		// start a new one.
+		calls, latest := b.detach(p)
+		b.mu.Unlock()
+		b.start(calls, latest)
+	} else {
+		if ok {
+			if at := deadline.Add(-b.opts.margin); at.Before(p.flushAt) {
+				p.flushAt = at
+				p.timer.Reset(time.Until(at))
+			}
+		}
+		b.mu.Unlock()
+	}
+
+	select {
+	case <-c.done:
+		return c.res, c.err
+	case <-ctx.Done():
+	}
+	b.mu.Lock()
+	if !p.flushed {
+		b.remove(p, c)
+	}
+	b.mu.Unlock()
+	return zero, context.Cause(ctx)
+}
+
+// remove takes c out of p; b.mu must be held.
+func (b *Batcher[T, R]) remove(p *pending[T, R], c *call[T, R]) {
+	for i, pc := range p.calls {
+		if pc == c {
+			p.calls = append(p.calls[:i], p.calls[i+1:]...)
+			break
+		}
+	}
+	if len(p.calls) == 0 {
+		p.flushed = true
+		p.timer.Stop()
+		if b.cur == p {
+			b.cur = nil
+		}
+	}
+}
+
+// flush runs p, unless it has already been flushed or emptied.
+func (b *Batcher[T, R]) flush(p *pending[T, R]) {
+	b.mu.Lock()
+	if p.flushed {
+		b.mu.Unlock()
+		return
+	}
+	calls, latest := b.detach(p)
+	b.mu.Unlock()
+	b.start(calls, latest)
+}
+
+// detach marks p as flushed and takes it out of b, so that no more calls
+// join it, and returns what start needs to run it; b.mu must be held.
+func (b *Batcher[T, R]) detach(p *pending[T, R]) ([]*call[T, R], time.Time) {
+	p.flushed = true
+	p.timer.Stop()
+	if b.cur == p {
+		b.cur = nil
+	}
+	b.wg.Add(1)
+	return p.calls, p.latest
+}
+
+// start runs a detached batch in its own goroutine.
+func (b *Batcher[T, R]) start(calls []*call[T, R], latest time.Time) {
+	go func() {
+		defer b.wg.Done()
+		b.run(calls, latest)
+	}()
+}
+
+func (b *Batcher[T, R]) run(calls []*call[T, R], latest time.Time) {
+	ctx := context.Background()
+	if !latest.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, latest)
+		defer cancel()
+	}
+	items := make([]T, len(calls))
+	for i, c := range calls {
+		items[i] = c.item
+	}
+	res, err := b.fn(ctx, items)
+	if err == nil && len(res) != len(items) {
+		err = fmt.Errorf("batch: got %d results for %d items", len(res), len(items))
+	}
+	for i, c := range calls {
+		if err != nil {
+			c.err = err
+		} else {
+			c.res = res[i]
+		}
+		close(c.done)
+	}
+}
+
+// Close flushes the current batch and waits for every batch to finish.
+// Later calls to Add return ErrClosed.
+func (b *Batcher[T, R]) Close() {
+	b.mu.Lock()
+	b.closed = true
+	if p := b.cur; p != nil {
+		calls, latest := b.detach(p)
+		b.mu.Unlock()
+		b.start(calls, latest)
+	} else {
+		b.mu.Unlock()
+	}
+	b.wg.Wait()
+}
@@ -0,0 +1,207 @@
+package batch
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recorder is a Func that doubles its items and records the batches it
+// was given.
+type recorder struct {
+	mu      sync.Mutex
+	batches [][]int
+}
+
+func (r *recorder) fn(_ context.Context, items []int) ([]int, error) {
+	r.mu.Lock()
+	r.batches = append(r.batches, append([]int(nil), items...))
+	r.mu.Unlock()
+	res := make([]int, len(items))
+	for i, v := range items {
+		res[i] = v * 2
+	}
+	return res, nil
+}
+
+func (r *recorder) sizes() []int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var sizes []int
+	for _, b := range r.batches {
+		sizes = append(sizes, len(b))
+	}
+	return sizes
+}
+
+// addAll adds items concurrently and checks every result.
+func addAll(t *testing.T, b *Batcher[int, int], n int) {
+	t.Helper()
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if v, err := b.Add(context.Background(), i); err != nil || v != i*2 {
+				errs <- errors.Join(err, errors.New("wrong result"))
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func TestGroupsConcurrentCalls(t *testing.T) {
+	var r recorder
+	b := New(r.fn, MaxDelay(50*time.Millisecond))
+	defer b.Close()
+	addAll(t, b, 10)
+	if sizes := r.sizes(); len(sizes) != 1 || sizes[0] != 10 {
+		t.Errorf("batch sizes = %v, want [10]", sizes)
+	}
+}
+
+func TestMaxSizeUnderConcurrency(t *testing.T) {
+	var r recorder
+	b := New(r.fn, MaxSize(4), MaxDelay(time.Hour))
+	defer b.Close()
+	addAll(t, b, 400)
+	total := 0
+	for _, n := range r.sizes() {
+		if n > 4 {
+			t.Errorf("batch of %d items, want at most 4", n)
+		}
+		total += n
+	}
+	if total != 400 {
+		t.Errorf("%d items batched, want 400", total)
+	}
+}
+
+func TestMaxDelayFlushes(t *testing.T) {
+	var r recorder
+	b := New(r.fn, MaxDelay(20*time.Millisecond))
+	defer b.Close()
+	start := time.Now()
+	if v, err := b.Add(context.Background(), 1); v != 2 || err != nil {
+		t.Fatalf("Add = %d, %v, want 2", v, err)
+	}
+	if d := time.Since(start); d < 20*time.Millisecond {
+		t.Errorf("flushed after %v, want MaxDelay", d)
+	}
+}
+
+func TestDeadlineFlushesEarly(t *testing.T) {
+	var deadline time.Time
+	b := New(func(ctx context.Context, items []int) ([]int, error) {
+		deadline, _ = ctx.Deadline()
+		return items, nil
+	}, MaxDelay(time.Hour), Margin(5*time.Millisecond))
+	defer b.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	if _, err := b.Add(ctx, 1); err != nil {
+		t.Fatalf("Add = %v, want the batch flushed before the deadline", err)
+	}
+	if want, _ := ctx.Deadline(); !deadline.Equal(want) {
+		t.Errorf("Func deadline = %v, want the caller's %v", deadline, want)
+	}
+}
+
+func TestNoDeadlineWhenACallerHasNone(t *testing.T) {
+	hasDeadline := make(chan bool, 1)
+	b := New(func(ctx context.Context, items []int) ([]int, error) {
+		_, ok := ctx.Deadline()
+		hasDeadline <- ok
+		return items, nil
+	}, MaxSize(2), MaxDelay(time.Hour))
+	defer b.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+	go b.Add(ctx, 1)
+	b.Add(context.Background(), 2)
+	if <-hasDeadline {
+		t.Error("Func has a deadline though one caller has none")
+	}
+}
+
+func TestCancelledCallLeavesBatch(t *testing.T) {
+	var r recorder
+	b := New(r.fn, MaxDelay(50*time.Millisecond))
+	defer b.Close()
+
+	stop := errors.New("stop")
+	ctx, cancel := context.WithCancelCause(context.Background())
+	time.AfterFunc(10*time.Millisecond, func() { cancel(stop) })
+	done := make(chan error, 1)
+	go func() {
+		_, err := b.Add(ctx, 1)
+		done <- err
+	}()
+	if v, err := b.Add(context.Background(), 2); v != 4 || err != nil {
+		t.Fatalf("Add = %d, %v, want 4", v, err)
+	}
+	if err := <-done; err != stop {
+		t.Errorf("cancelled Add = %v, want the cause of ctx", err)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.batches) != 1 || len(r.batches[0]) != 1 || r.batches[0][0] != 2 {
+		t.Errorf("batches = %v, want [[2]]", r.batches)
+	}
+}
+
+func TestFuncErrors(t *testing.T) {
+	boom := errors.New("boom")
+	b := New(func(context.Context, []int) ([]int, error) { return nil, boom }, MaxSize(1))
+	defer b.Close()
+	if _, err := b.Add(context.Background(), 1); err != boom {
+		t.Errorf("Add = %v, want the Func's error", err)
+	}
+
+	short := New(func(context.Context, []int) ([]int, error) { return nil, nil }, MaxSize(1))
+	defer short.Close()
+	if _, err := short.Add(context.Background(), 1); err == nil {
+		t.Error("Add succeeded with too few results")
+	}
+}
+
+func TestClose(t *testing.T) {
+	var r recorder
+	b := New(r.fn, MaxDelay(time.Hour))
+	done := make(chan error, 1)
+	go func() {
+		_, err := b.Add(context.Background(), 1)
+		done <- err
+	}()
+	for len(r.sizes()) == 0 {
+		b.mu.Lock()
+		waiting := b.cur != nil
+		b.mu.Unlock()
+		if waiting {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	b.Close()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Add = %v, want the batch flushed by Close", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close did not flush the pending batch")
+	}
+	if _, err := b.Add(context.Background(), 2); err != ErrClosed {
+		t.Errorf("Add after Close = %v, want ErrClosed", err)
+	}
+}
@@ -0,0 +1,107 @@
+// Package blocking runs functions that take no context under one.
+//
+// A legacy call that cannot be cancelled can still be waited for with a
+// deadline: Call runs it in its own goroutine and returns as soon as the
+// context ends. The call itself keeps running until it returns on its own,
+// so Call can interrupt it with a hook, such as closing the connection it
+// is blocked on, and counts the calls it had to abandon in Orphaned.
+package blocking
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// orphaned counts the calls abandoned by Call that have not returned yet.
+var orphaned atomic.Int64
+
+// Orphaned returns the number of calls that Call stopped waiting for and
+// that are still running. A number that keeps growing means the interrupt
+// hooks are not unblocking the calls; services can export it with their
+// own metrics.
+func Orphaned() int64 {
+	return orphaned.Load()
+}
+
+type options struct {
+	interrupt func()
+	grace     time.Duration
+}
+
+// Option configures Call.
+type Option func(*options)
+
+// Interrupt sets a function that Call runs when ctx ends before the call
+// returns. It should make the call return, for example by closing the
+// connection or file it is blocked on.
+func Interrupt(fn func()) Option {
+	return func(o *options) { o.interrupt = fn }
+}
+
+// Grace makes Call wait up to d for the call to return after ctx ends and
+// the interrupt hook has run, before abandoning it. A call that returns
+// within the grace period is not orphaned, and its result is discarded.
+func Grace(d time.Duration) Option {
+	return func(o *options) { o.grace = d }
+}
+
+const (
+	running int32 = iota
+	finished
+	abandoned
+)
+
+type result[T any] struct {
+	v   T
+	err error
+}
+
+// Call runs fn in a new goroutine and returns its result, or the cause of
+// ctx if ctx ends first. In that case fn is left running: it is counted in
+// Orphaned until it returns, and whatever it returns is dropped, so fn must
+// not hand back resources that need releasing.
+//
+// If ctx has already ended, fn is not called.
+func Call[T any](ctx context.Context, fn func() (T, error), opts ...Option) (T, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	var zero T
+	if ctx.Err() != nil {
+		return zero, context.Cause(ctx)
+	}
+
+	var state atomic.Int32
+	done := make(chan result[T], 1)
+	go func() {
+		v, err := fn()
+		done <- result[T]{v, err}
+		if !state.CompareAndSwap(running, finished) {
+			orphaned.Add(-1)
+		}
+	}()
+
+	select {
+	case r := <-done:
+		return r.v, r.err
+	case <-ctx.Done():
+	}
+	if o.interrupt != nil {
+		o.interrupt()
+	}
+	if o.grace > 0 {
+		t := time.NewTimer(o.grace)
+		defer t.Stop()
+		select {
+		case <-done:
+			return zero, context.Cause(ctx)
+		case <-t.C:
+		}
+	}
+	if state.CompareAndSwap(running, abandoned) {
+		orphaned.Add(1)
+	}
+	return zero, context.Cause(ctx)
+}
@@ -0,0 +1,107 @@
+package blocking
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// waitOrphaned waits for Orphaned to reach want.
+func waitOrphaned(t *testing.T, want int64) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for Orphaned() != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("Orphaned = %d, want %d", Orphaned(), want)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestCallReturnsResult(t *testing.T) {
+	v, err := Call(context.Background(), func() (int, error) { return 42, nil })
+	if v != 42 || err != nil {
+		t.Errorf("Call = %d, %v, want 42", v, err)
+	}
+	boom := errors.New("boom")
+	if _, err := Call(context.Background(), func() (int, error) { return 0, boom }); err != boom {
+		t.Errorf("Call = %v, want %v", err, boom)
+	}
+}
+
+func TestCallAbandonsOnCancel(t *testing.T) {
+	before := Orphaned()
+	release := make(chan struct{})
+	stop := errors.New("stop")
+	ctx, cancel := context.WithCancelCause(context.Background())
+	time.AfterFunc(10*time.Millisecond, func() { cancel(stop) })
+
+	_, err := Call(ctx, func() (int, error) {
+		<-release
+		return 1, nil
+	})
+	if !errors.Is(err, stop) {
+		t.Fatalf("Call = %v, want the cause of ctx", err)
+	}
+	if got := Orphaned(); got != before+1 {
+		t.Errorf("Orphaned = %d, want %d while the call runs", got, before+1)
+	}
+	close(release)
+	waitOrphaned(t, before)
+}
+
+func TestInterruptWithinGrace(t *testing.T) {
+	before := Orphaned()
+	release := make(chan struct{})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err := Call(ctx, func() (int, error) {
+		<-release
+		return 1, nil
+	}, Interrupt(func() { close(release) }), Grace(5*time.Second))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Call = %v, want the deadline", err)
+	}
+	if got := Orphaned(); got != before {
+		t.Errorf("Orphaned = %d, want %d: the call returned within the grace period", got, before)
+	}
+}
+
+func TestGraceExpires(t *testing.T) {
+	before := Orphaned()
+	release := make(chan struct{})
+	interrupted := false
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	Call(ctx, func() (int, error) {
+		<-release
+		return 1, nil
+	}, Interrupt(func() { interrupted = true }), Grace(20*time.Millisecond))
+	if d := time.Since(start); d < 30*time.Millisecond {
+		t.Errorf("Call returned after %v, want it to wait out the grace period", d)
+	}
+	if !interrupted {
+		t.Error("interrupt hook not run")
+	}
+	if got := Orphaned(); got != before+1 {
+		t.Errorf("Orphaned = %d, want %d", got, before+1)
+	}
+	close(release)
+	waitOrphaned(t, before)
+}
+
+func TestEndedContextSkipsCall(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	called := false
+	if _, err := Call(ctx, func() (int, error) { called = true; return 0, nil }); !errors.Is(err, context.Canceled) {
+		t.Errorf("Call = %v, want context.Canceled", err)
+	}
+	if called {
+		t.Error("fn called with an ended context")
+	}
+}
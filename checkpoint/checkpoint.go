@@ -0,0 +1,109 @@
+// Package checkpoint adds cheap cancellation checks to CPU-bound loops.
+//
+// A loop that never blocks has no select to hang a ctx.Done() case on.
+// Calling Check on every iteration costs a decrement and a comparison; it
+// looks at the context only every N iterations, or about every M of
+// elapsed time if that comes sooner.
+package checkpoint
+
+import (
+	"context"
+	"time"
+)
+
+// DefaultEvery is the number of iterations between checks when Every is not
+// given.
+const DefaultEvery = 1024
+
+// Option configures a Checkpoint.
+type Option func(*Checkpoint)
+
+// Every checks the context once every n calls to Check.
+func Every(n int) Option {
+	return func(c *Checkpoint) { c.every = uint32(max(n, 1)) }
+}
+
+// Interval also checks the context whenever d has passed, even if fewer
+// than Every calls were made, so that a loop with slow iterations notices
+// cancellation in time. Rather than reading the clock on every call, each
+// check times the calls since the previous one and schedules the next check
+// about d later, assuming iterations keep taking as long.
+func Interval(d time.Duration) Option {
+	return func(c *Checkpoint) { c.interval = d }
+}
+
+// Checkpoint polls a context from a loop. It is not safe for concurrent use;
+// give each goroutine its own.
+type Checkpoint struct {
+	// ctx is held for the lifetime of one loop, much like a Ticker.
+	ctx      context.Context
+	done     <-chan struct{}
+	every    uint32
+	interval time.Duration
+
+	// left counts down the calls until the next check, out of batch.
+	left  uint32
+	batch uint32
+	last  time.Time
+}
+
+// New returns a Checkpoint for ctx. Without options it checks the context
+// every DefaultEvery calls.
+func New(ctx context.Context, opts ...Option) *Checkpoint {
+	c := &Checkpoint{ctx: ctx, done: ctx.Done(), every: DefaultEvery}
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.batch = c.every
+	if c.interval > 0 {
+		// Time the first call to learn how long iterations take.
+		c.batch = 1
+		c.last = time.Now()
+	}
+	c.left = c.batch
+	return c
+}
+
+// Check returns the cause of the context if this call is one that checks it
+// and finds it ended, and nil otherwise. The loop is expected to stop on
+// the first error.
+func (c *Checkpoint) Check() error {
+	if c.left--; c.left != 0 {
+		return nil
+	}
+	return c.check()
+}
+
+// check is kept out of Check so that Check stays small enough to inline.
+func (c *Checkpoint) check() error {
+	if c.interval > 0 {
+		c.schedule()
+	}
+	c.left = c.batch
+	select {
+	case <-c.done:
+		return context.Cause(c.ctx)
+	default:
+		return nil
+	}
+}
+
+// schedule sets the number of calls until the next check so that it comes
+// one interval after this one at the pace of the last batch, but no more
+// than every calls away. The batch at most doubles each time, so one
+// unusually fast batch cannot push the next check far out.
+func (c *Checkpoint) schedule() {
+	now := time.Now()
+	elapsed := now.Sub(c.last)
+	c.last = now
+	next := c.every
+	if c.batch <= c.every/2 {
+		next = c.batch * 2
+	}
+	limit := float64(next)
+	if elapsed > 0 {
+		perCall := float64(elapsed) / float64(c.batch)
+		limit = min(limit, float64(c.interval)/perCall)
+	}
+	c.batch = uint32(max(1, limit))
+}
@@ -0,0 +1,132 @@
+package checkpoint
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestEvery(t *testing.T) {
+	stop := errors.New("stop")
+	ctx, cancel := context.WithCancelCause(context.Background())
+	cancel(stop)
+
+	cp := New(ctx, Every(4))
+	for i := 1; i <= 3; i++ {
+		if err := cp.Check(); err != nil {
+			t.Fatalf("call %d checked the context: %v", i, err)
+		}
+	}
+	if err := cp.Check(); !errors.Is(err, stop) {
+		t.Errorf("call 4 = %v, want the cause of ctx", err)
+	}
+}
+
+func TestDefaultEvery(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	cp := New(ctx)
+	for i := 1; i < DefaultEvery; i++ {
+		if cp.Check() != nil {
+			t.Fatalf("call %d checked the context", i)
+		}
+	}
+	if cp.Check() == nil {
+		t.Errorf("call %d did not check the context", DefaultEvery)
+	}
+}
+
+func TestLiveContext(t *testing.T) {
+	cp := New(context.Background(), Every(1))
+	for range 10 {
+		if err := cp.Check(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// TestIntervalWithSlowIterations checks that Interval bounds the time to
+// notice cancellation when Every alone would take far too long.
+func TestIntervalWithSlowIterations(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	cp := New(ctx, Every(1_000_000), Interval(10*time.Millisecond))
+
+	start := time.Now()
+	for {
+		if err := cp.Check(); err != nil {
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Fatalf("Check = %v, want the deadline", err)
+			}
+			break
+		}
+		if time.Since(start) > 5*time.Second {
+			t.Fatal("cancellation not noticed")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("noticed cancellation after %v, want about 60ms", d)
+	}
+}
+
+func TestIntervalKeepsEveryWithFastIterations(t *testing.T) {
+	cp := New(context.Background(), Every(100), Interval(time.Hour))
+	for range 1000 {
+		cp.Check()
+	}
+	if cp.batch != 100 {
+		t.Errorf("batch = %d, want Every to cap it", cp.batch)
+	}
+}
+
+// sink keeps the compiler from optimising the loops away.
+var sink int
+
+// work is a tiny CPU-bound step, so the cost of a check is easy to see.
+func work(i int) int {
+	return i*i ^ i>>3
+}
+
+func BenchmarkNoCheck(b *testing.B) {
+	s := 0
+	for i := 0; i < b.N; i++ {
+		s += work(i)
+	}
+	sink = s
+}
+
+func BenchmarkSelectEveryIteration(b *testing.B) {
+	ctx := context.Background()
+	s := 0
+	for i := 0; i < b.N; i++ {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+		s += work(i)
+	}
+	sink = s
+}
+
+func benchmarkCheck(b *testing.B, opts ...Option) {
+	cp := New(context.Background(), opts...)
+	s := 0
+	for i := 0; i < b.N; i++ {
+		if cp.Check() != nil {
+			return
+		}
+		s += work(i)
+	}
+	sink = s
+}
+
+func BenchmarkCheckEvery1(b *testing.B) { benchmarkCheck(b, Every(1)) }
+
+func BenchmarkCheckDefault(b *testing.B) { benchmarkCheck(b) }
+
+func BenchmarkCheckInterval(b *testing.B) {
+	benchmarkCheck(b, Every(1<<20), Interval(100*time.Microsecond))
+}
@@ -0,0 +1,48 @@
+//go:build ignore
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/surenraju-zz/go-context/scope"
+)
+
+func main() {
+	// Create an HTTP server that listens on port 8000
+	http.ListenAndServe(":8000", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The scope ends with the request, whether it completes or is cancelled
+		s, ctx := scope.WithContext(r.Context())
+		defer func() {
+			// Release everything now, and report anything that failed to clean up
+			if err := s.Close(); err != nil {
+				fmt.Fprintln(os.Stderr, "cleanup:", err)
+			}
+		}()
+
+		// The temporary file is closed and removed however the handler exits
+		f, err := scope.TempFile(ctx, "", "upload-*")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		scope.Defer(ctx, func() error {
+			fmt.Fprintln(os.Stdout, "removed", f.Name())
+			return nil
+		})
+		io.Copy(f, r.Body)
+
+		select {
+		case <-time.After(2 * time.Second):
+			// Simulate some useful work on the uploaded data
+			w.Write([]byte("request processed"))
+		case <-ctx.Done():
+			// The cleanups are already running by the time we get here
+			fmt.Fprint(os.Stderr, "request cancelled\n")
+		}
+	}))
+}
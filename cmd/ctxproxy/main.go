@@ -0,0 +1,35 @@
+// Command ctxproxy is a deadline-aware reverse proxy.
+//
+// It forwards requests to an upstream such as the server in
+// cancel_listen.go, trimming the caller's X-Request-Timeout budget by a
+// margin and cancelling the upstream request when the client disconnects.
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/surenraju-zz/go-context/proxy"
+)
+
+func main() {
+	listen := flag.String("listen", ":8080", "address to listen on")
+	upstream := flag.String("upstream", "http://localhost:8000", "upstream base URL")
+	margin := flag.Duration("margin", 50*time.Millisecond, "time kept back from each request's budget")
+	maxTimeout := flag.Duration("max-timeout", 30*time.Second, "budget for requests without one (0 for none)")
+	flag.Parse()
+
+	u, err := url.Parse(*upstream)
+	if err != nil {
+		log.Fatalf("invalid upstream: %v", err)
+	}
+	p := proxy.New(u, nil)
+	p.Margin = *margin
+	p.MaxTimeout = *maxTimeout
+
+	log.Printf("proxying %s to %s", *listen, u)
+	log.Fatal(http.ListenAndServe(*listen, p))
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+type scenario struct {
+	name    string
+	summary string
+	// run parses args with fs and plays the scenario, recording on tl.
+	run func(fs *flag.FlagSet, args []string, tl *timeline) error
+}
+
+var scenarios = []scenario{
+	{"listen", "an HTTP handler stops working when its client goes away", listenDemo},
+	{"emit", "a failing operation cancels its sibling", emitDemo},
+	{"timeout", "work is cancelled after a timeout", timeoutDemo},
+	{"deadline", "workers share a deadline", deadlineDemo},
+	{"value", "a request-scoped value flows to every goroutine", valueDemo},
+}
+
+func demo(args []string) int {
+	if len(args) == 0 {
+		usage()
+		return 2
+	}
+	for _, s := range scenarios {
+		if s.name != args[0] {
+			continue
+		}
+		fs := flag.NewFlagSet("demo "+s.name, flag.ContinueOnError)
+		tl := newTimeline()
+		err := s.run(fs, args[1:], tl)
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		if errors.As(err, new(*usageError)) {
+			return 2
+		}
+		if errors.As(err, new(*net.OpError)) {
+			fmt.Fprintln(os.Stderr, "go-context:", err)
+			return 1
+		}
+		// Cancellation is the point of most scenarios, so an error here is
+		// an outcome to show rather than a failure of the command.
+		tl.print(os.Stdout)
+		if err == nil {
+			fmt.Println("\nresult: ok")
+		} else {
+			fmt.Println("\nresult:", err)
+		}
+		return 0
+	}
+	fmt.Fprintf(os.Stderr, "go-context: unknown scenario %q\n", args[0])
+	usage()
+	return 2
+}
+
+// usageError is an invalid command line, already reported with the
+// scenario's usage.
+type usageError struct {
+	err error
+}
+
+func (e *usageError) Error() string { return e.err.Error() }
+
+// parseFlags parses args into fs. The FlagSet reports bad flags itself;
+// they are returned as a *usageError.
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	err := fs.Parse(args)
+	if err == nil || errors.Is(err, flag.ErrHelp) {
+		return err
+	}
+	return &usageError{err}
+}
+
+// badFlag reports a flag value that parsed but is out of range, in the same
+// way as the FlagSet reports the others.
+func badFlag(fs *flag.FlagSet, format string, args ...any) error {
+	err := fmt.Errorf(format, args...)
+	fmt.Fprintln(fs.Output(), err)
+	fs.Usage()
+	return &usageError{err}
+}
+
+// work simulates d of useful work that gives up when ctx ends.
+func work(ctx context.Context, tl *timeline, who string, d time.Duration) error {
+	tl.record(who, "started, %v of work to do", d)
+	defer tl.record(who, "exited")
+	select {
+	case <-time.After(d):
+		tl.record(who, "finished its work")
+		return nil
+	case <-ctx.Done():
+		tl.record(who, "cancelled: %v", context.Cause(ctx))
+		return context.Cause(ctx)
+	}
+}
+
+func listenDemo(fs *flag.FlagSet, args []string, tl *timeline) error {
+	workFor := fs.Duration("work", 2*time.Second, "how long the handler works")
+	cancelAfter := fs.Duration("cancel-after", time.Second, "when the client gives up (0 to wait)")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return err
+	}
+	started, handled := make(chan struct{}), make(chan struct{})
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		defer close(handled)
+		if work(r.Context(), tl, "handler", *workFor) == nil {
+			w.Write([]byte("request processed"))
+		}
+	})}
+	go srv.Serve(l)
+	defer srv.Close()
+
+	ctx := context.Background()
+	if *cancelAfter > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *cancelAfter)
+		defer cancel()
+	}
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+l.Addr().String(), nil)
+	tl.record("client", "sending request")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		tl.record("client", "gave up: %v", err)
+	} else {
+		body, _ := io.ReadAll(res.Body)
+		res.Body.Close()
+		tl.record("client", "received %q", body)
+	}
+	// A client that gives up before its request is delivered never reaches
+	// the handler, so only wait for a handler that has started.
+	select {
+	case <-started:
+		<-handled
+	default:
+	}
+	return err
+}
+
+func emitDemo(fs *flag.FlagSet, args []string, tl *timeline) error {
+	fail := fs.Bool("fail", true, "whether operation1 fails")
+	failAfter := fs.Duration("fail-after", 100*time.Millisecond, "how long operation1 runs")
+	workFor := fs.Duration("work", 500*time.Millisecond, "how long operation2 works")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+
+	done := make(chan struct{})
+	defer func() { <-done }()
+	go func() {
+		defer close(done)
+		tl.record("operation1", "started")
+		time.Sleep(*failAfter)
+		if *fail {
+			err := errors.New("operation1 failed")
+			tl.record("operation1", "failed, cancelling the shared context")
+			cancel(err)
+		}
+		tl.record("operation1", "exited")
+	}()
+	return work(ctx, tl, "operation2", *workFor)
+}
+
+func timeoutDemo(fs *flag.FlagSet, args []string, tl *timeline) error {
+	timeout := fs.Duration("timeout", 100*time.Millisecond, "timeout passed to WithTimeout")
+	workFor := fs.Duration("work", 500*time.Millisecond, "how long the work takes")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	tl.record("main", "WithTimeout(%v)", *timeout)
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	return work(ctx, tl, "worker", *workFor)
+}
+
+func deadlineDemo(fs *flag.FlagSet, args []string, tl *timeline) error {
+	in := fs.Duration("in", 250*time.Millisecond, "deadline, relative to now")
+	workers := fs.Int("workers", 3, "number of workers; worker i works i times -step")
+	step := fs.Duration("step", 100*time.Millisecond, "work added per worker")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *workers < 1 {
+		return badFlag(fs, "-workers must be at least 1, got %d", *workers)
+	}
+
+	deadline := time.Now().Add(*in)
+	tl.record("main", "WithDeadline(now+%v)", *in)
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	errs := make([]error, *workers)
+	for i := range *workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = work(ctx, tl, fmt.Sprintf("worker%d", i+1), time.Duration(i+1)**step)
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+type valueKey struct{}
+
+func valueDemo(fs *flag.FlagSet, args []string, tl *timeline) error {
+	value := fs.String("value", "user-42", "value stored under the key")
+	drop := fs.Bool("drop", false, "start the backend from context.Background() instead, losing the value")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	ctx := context.WithValue(context.Background(), valueKey{}, *value)
+	tl.record("main", "WithValue(key, %q)", *value)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		tl.record("handler", "sees %v", ctx.Value(valueKey{}))
+
+		// Derived contexts keep the values of their parent.
+		child, cancel := context.WithCancel(ctx)
+		defer cancel()
+		if *drop {
+			child = context.Background()
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tl.record("backend", "sees %v", child.Value(valueKey{}))
+		}()
+	}()
+	wg.Wait()
+	return nil
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// play runs the named scenario with args and returns its timeline and
+// error, failing the test if it does not return in time.
+func play(t *testing.T, name string, args ...string) (*timeline, error) {
+	t.Helper()
+	for _, s := range scenarios {
+		if s.name != name {
+			continue
+		}
+		fs := flag.NewFlagSet(name, flag.ContinueOnError)
+		fs.SetOutput(io.Discard)
+		tl := newTimeline()
+		errc := make(chan error, 1)
+		go func() { errc <- s.run(fs, args, tl) }()
+		select {
+		case err := <-errc:
+			return tl, err
+		case <-time.After(5 * time.Second):
+			t.Fatalf("scenario %s %q did not return", name, args)
+		}
+	}
+	t.Fatalf("no scenario %s", name)
+	return nil, nil
+}
+
+// saw reports whether tl recorded what for who.
+func saw(tl *timeline, who, what string) bool {
+	tl.mu.Lock()
+	defer tl.mu.Unlock()
+	for _, e := range tl.events {
+		if e.who == who && strings.Contains(e.what, what) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestListen(t *testing.T) {
+	tl, err := play(t, "listen", "-work", "10ms", "-cancel-after", "0")
+	if err != nil {
+		t.Fatalf("listen = %v", err)
+	}
+	if !saw(tl, "client", `received "request processed"`) {
+		t.Error("client did not receive the response")
+	}
+}
+
+func TestListenClientGivesUp(t *testing.T) {
+	tl, err := play(t, "listen", "-work", "5s", "-cancel-after", "50ms")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("listen = %v, want the client's deadline", err)
+	}
+	if !saw(tl, "handler", "cancelled") {
+		t.Error("handler was not cancelled")
+	}
+}
+
+func TestListenClientGivesUpBeforeDelivery(t *testing.T) {
+	if _, err := play(t, "listen", "-cancel-after", "1ns"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("listen = %v, want the client's deadline", err)
+	}
+}
+
+func TestEmit(t *testing.T) {
+	_, err := play(t, "emit", "-fail-after", "10ms", "-work", "5s")
+	if err == nil || err.Error() != "operation1 failed" {
+		t.Errorf("emit = %v, want operation1's failure", err)
+	}
+	if _, err := play(t, "emit", "-fail=false", "-fail-after", "1ms", "-work", "10ms"); err != nil {
+		t.Errorf("emit without failure = %v", err)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	if _, err := play(t, "timeout", "-timeout", "10ms", "-work", "5s"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("timeout = %v, want the deadline", err)
+	}
+}
+
+func TestDeadline(t *testing.T) {
+	tl, err := play(t, "deadline", "-in", "50ms", "-workers", "2", "-step", "30ms")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("deadline = %v, want the second worker to miss it", err)
+	}
+	if !saw(tl, "worker1", "finished") || !saw(tl, "worker2", "cancelled") {
+		t.Error("want worker1 to finish and worker2 to be cancelled")
+	}
+}
+
+func TestValue(t *testing.T) {
+	tl, _ := play(t, "value", "-value", "v1")
+	if !saw(tl, "backend", "sees v1") {
+		t.Error("backend did not see the value")
+	}
+	tl, _ = play(t, "value", "-value", "v1", "-drop")
+	if !saw(tl, "backend", "sees <nil>") {
+		t.Error("backend saw the value after it was dropped")
+	}
+}
+
+func TestBadFlag(t *testing.T) {
+	if _, err := play(t, "timeout", "-bogus"); err == nil {
+		t.Error("unknown flag accepted")
+	}
+}
+
+func TestBadFlags(t *testing.T) {
+	for _, args := range [][]string{{"-workers", "0"}, {"-workers", "-1"}, {"-workers", "x"}} {
+		tl, err := play(t, "deadline", args...)
+		if !errors.As(err, new(*usageError)) {
+			t.Errorf("deadline %q = %v, want a usage error", args, err)
+		}
+		if len(tl.events) != 0 {
+			t.Errorf("deadline %q ran with bad flags", args)
+		}
+	}
+}
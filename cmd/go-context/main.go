@@ -0,0 +1,40 @@
+// Command go-context runs the scenarios from the README so they can be
+// explored without editing code.
+//
+// Usage:
+//
+//	go-context demo <scenario> [flags]
+//
+// The scenarios are listen, emit, timeout, deadline and value. Run
+// "go-context demo <scenario> -h" to see the flags of each one.
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	switch os.Args[1] {
+	case "demo":
+		os.Exit(demo(os.Args[2:]))
+	case "help", "-h", "-help", "--help":
+		usage()
+	default:
+		fmt.Fprintf(os.Stderr, "go-context: unknown command %q\n", os.Args[1])
+		usage()
+		os.Exit(2)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: go-context demo <scenario> [flags]")
+	fmt.Fprintln(os.Stderr, "\nscenarios:")
+	for _, s := range scenarios {
+		fmt.Fprintf(os.Stderr, "  %-9s %s\n", s.name, s.summary)
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// timeline records when each goroutine of a scenario started, was
+// cancelled and exited.
+type timeline struct {
+	start time.Time
+
+	mu     sync.Mutex
+	events []event
+}
+
+type event struct {
+	at   time.Duration
+	who  string
+	what string
+}
+
+func newTimeline() *timeline {
+	return &timeline{start: time.Now()}
+}
+
+func (t *timeline) record(who, format string, args ...any) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.events = append(t.events, event{at: time.Since(t.start), who: who, what: fmt.Sprintf(format, args...)})
+}
+
+func (t *timeline) print(w io.Writer) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	width := 0
+	for _, e := range t.events {
+		width = max(width, len(e.who))
+	}
+	fmt.Fprintln(w, "\ntimeline:")
+	for _, e := range t.events {
+		fmt.Fprintf(w, "  %+8.3fs  %-*s  %s\n", e.at.Seconds(), width, e.who, e.what)
+	}
+}
@@ -0,0 +1,187 @@
+// Package combine runs several context-aware functions concurrently and
+// combines their outcomes.
+//
+// Each combinator gives every function a context derived from the caller's
+// and cancels the ones whose result is no longer needed, with ErrNotNeeded
+// as the cause.
+package combine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ErrNotNeeded is the cancellation cause seen by calls whose result stopped
+// mattering, because enough other calls succeeded or one failed.
+var ErrNotNeeded = errors.New("combine: result no longer needed")
+
+// Func is a function run by a combinator.
+type Func[T any] func(ctx context.Context) (T, error)
+
+// Result is the outcome of the call at Index.
+type Result[T any] struct {
+	Index int
+	Value T
+	Err   error
+}
+
+// CallError is the error of the call at Index.
+type CallError struct {
+	Index int
+	Err   error
+}
+
+func (e *CallError) Error() string {
+	return fmt.Sprintf("call %d: %v", e.Index, e.Err)
+}
+
+func (e *CallError) Unwrap() error { return e.Err }
+
+// Errors collects the errors of several calls, in call order.
+type Errors []*CallError
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return "combine: " + strings.Join(msgs, "; ")
+}
+
+func (e Errors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, err := range e {
+		errs[i] = err
+	}
+	return errs
+}
+
+// start runs every fn with a context derived from ctx and returns their
+// results in completion order on a channel buffered for all of them, so
+// that callers may stop reading early without leaking goroutines.
+func start[T any](ctx context.Context, fns []Func[T]) <-chan Result[T] {
+	results := make(chan Result[T], len(fns))
+	for i, fn := range fns {
+		go func() {
+			v, err := fn(ctx)
+			results <- Result[T]{Index: i, Value: v, Err: err}
+		}()
+	}
+	return results
+}
+
+// First returns the value of the first call to succeed and cancels the
+// others. If every call fails, it returns Errors holding all of them. If
+// ctx ends first, it returns the cause of ctx.
+func First[T any](ctx context.Context, fns ...Func[T]) (T, error) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(ErrNotNeeded)
+
+	var zero T
+	results := start(ctx, fns)
+	errs := make(Errors, 0, len(fns))
+	for range fns {
+		select {
+		case r := <-results:
+			if r.Err == nil {
+				return r.Value, nil
+			}
+			errs = append(errs, &CallError{Index: r.Index, Err: r.Err})
+		case <-ctx.Done():
+			return zero, context.Cause(ctx)
+		}
+	}
+	sortErrors(errs)
+	return zero, errs
+}
+
+// All returns the values of every call, in call order. The first failure
+// cancels the remaining calls and is returned as a *CallError.
+func All[T any](ctx context.Context, fns ...Func[T]) ([]T, error) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(ErrNotNeeded)
+
+	values := make([]T, len(fns))
+	results := start(ctx, fns)
+	for range fns {
+		select {
+		case r := <-results:
+			if r.Err != nil {
+				return nil, &CallError{Index: r.Index, Err: r.Err}
+			}
+			values[r.Index] = r.Value
+		case <-ctx.Done():
+			return nil, context.Cause(ctx)
+		}
+	}
+	return values, nil
+}
+
+// QuorumError is returned by Quorum when too many calls failed for k of
+// them to succeed.
+type QuorumError struct {
+	Need      int
+	Succeeded int
+	Errors    Errors
+}
+
+func (e *QuorumError) Error() string {
+	return fmt.Sprintf("combine: quorum of %d not reached, %d succeeded: %v", e.Need, e.Succeeded, e.Errors)
+}
+
+func (e *QuorumError) Unwrap() error { return e.Errors }
+
+// Quorum returns as soon as k calls have succeeded, cancelling the rest.
+// The successful results are returned in completion order. Once so many
+// calls have failed that k can no longer succeed, Quorum returns the
+// partial successes with a *QuorumError holding every failure.
+func Quorum[T any](ctx context.Context, k int, fns ...Func[T]) ([]Result[T], error) {
+	if k <= 0 || k > len(fns) {
+		return nil, fmt.Errorf("combine: quorum of %d out of %d calls", k, len(fns))
+	}
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(ErrNotNeeded)
+
+	var ok []Result[T]
+	var errs Errors
+	results := start(ctx, fns)
+	for range fns {
+		select {
+		case r := <-results:
+			if r.Err == nil {
+				if ok = append(ok, r); len(ok) == k {
+					return ok, nil
+				}
+				continue
+			}
+			errs = append(errs, &CallError{Index: r.Index, Err: r.Err})
+			if len(fns)-len(errs) < k {
+				sortErrors(errs)
+				return ok, &QuorumError{Need: k, Succeeded: len(ok), Errors: errs}
+			}
+		case <-ctx.Done():
+			return ok, context.Cause(ctx)
+		}
+	}
+	panic("unreachable")
+}
+
+// Settled waits for every call and returns their outcomes in call order.
+// It never cancels a call itself; if ctx ends, the calls see that through
+// their own context and Settled still waits for them to return.
+func Settled[T any](ctx context.Context, fns ...Func[T]) []Result[T] {
+	out := make([]Result[T], len(fns))
+	results := start(ctx, fns)
+	for range fns {
+		r := <-results
+		out[r.Index] = r
+	}
+	return out
+}
+
+func sortErrors(errs Errors) {
+	slices.SortFunc(errs, func(a, b *CallError) int { return a.Index - b.Index })
+}
@@ -0,0 +1,151 @@
+package combine
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// after returns a Func that yields v, or err if set, after d unless its
+// context ends first. The cause of an ended context is sent on causes.
+func after[T any](d time.Duration, v T, err error, causes chan<- error) Func[T] {
+	return func(ctx context.Context) (T, error) {
+		select {
+		case <-time.After(d):
+			var zero T
+			if err != nil {
+				return zero, err
+			}
+			return v, nil
+		case <-ctx.Done():
+			if causes != nil {
+				causes <- context.Cause(ctx)
+			}
+			var zero T
+			return zero, context.Cause(ctx)
+		}
+	}
+}
+
+func waitNotNeeded(t *testing.T, causes <-chan error) {
+	t.Helper()
+	select {
+	case err := <-causes:
+		if !errors.Is(err, ErrNotNeeded) {
+			t.Errorf("cause = %v, want ErrNotNeeded", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("call was not cancelled")
+	}
+}
+
+func TestFirst(t *testing.T) {
+	causes := make(chan error, 1)
+	boom := errors.New("boom")
+	v, err := First(context.Background(),
+		after(0, 0, boom, nil),
+		after(10*time.Millisecond, 2, nil, nil),
+		after(5*time.Second, 3, nil, causes),
+	)
+	if err != nil || v != 2 {
+		t.Errorf("First = %d, %v, want 2", v, err)
+	}
+	waitNotNeeded(t, causes)
+}
+
+func TestFirstAllFail(t *testing.T) {
+	a, b := errors.New("a"), errors.New("b")
+	_, err := First(context.Background(),
+		after(10*time.Millisecond, 0, a, nil),
+		after(0, 0, b, nil),
+	)
+	var errs Errors
+	if !errors.As(err, &errs) || len(errs) != 2 || errs[0].Index != 0 || errs[1].Index != 1 {
+		t.Fatalf("First = %v, want both errors in call order", err)
+	}
+	if !errors.Is(err, a) || !errors.Is(err, b) {
+		t.Errorf("First = %v, want it to wrap both errors", err)
+	}
+}
+
+func TestAll(t *testing.T) {
+	vs, err := All(context.Background(),
+		after(20*time.Millisecond, "a", nil, nil),
+		after(0, "b", nil, nil),
+	)
+	if err != nil || len(vs) != 2 || vs[0] != "a" || vs[1] != "b" {
+		t.Errorf("All = %q, %v, want [a b]", vs, err)
+	}
+}
+
+func TestAllFailsFast(t *testing.T) {
+	causes := make(chan error, 1)
+	boom := errors.New("boom")
+	_, err := All(context.Background(),
+		after(5*time.Second, 1, nil, causes),
+		after(0, 0, boom, nil),
+	)
+	var ce *CallError
+	if !errors.As(err, &ce) || ce.Index != 1 || !errors.Is(err, boom) {
+		t.Errorf("All = %v, want a CallError for call 1", err)
+	}
+	waitNotNeeded(t, causes)
+}
+
+func TestQuorum(t *testing.T) {
+	causes := make(chan error, 1)
+	rs, err := Quorum(context.Background(), 2,
+		after(0, 1, nil, nil),
+		after(10*time.Millisecond, 2, nil, nil),
+		after(5*time.Second, 3, nil, causes),
+	)
+	if err != nil || len(rs) != 2 || rs[0].Index != 0 || rs[1].Index != 1 {
+		t.Errorf("Quorum = %+v, %v, want calls 0 and 1", rs, err)
+	}
+	waitNotNeeded(t, causes)
+}
+
+func TestQuorumUnreachable(t *testing.T) {
+	boom := errors.New("boom")
+	rs, err := Quorum(context.Background(), 2,
+		after(0, 1, nil, nil),
+		after(10*time.Millisecond, 0, boom, nil),
+		after(20*time.Millisecond, 0, boom, nil),
+	)
+	var qe *QuorumError
+	if !errors.As(err, &qe) || qe.Need != 2 || qe.Succeeded != 1 || len(qe.Errors) != 2 {
+		t.Fatalf("Quorum = %v, want a QuorumError with 1 success and 2 failures", err)
+	}
+	if len(rs) != 1 || rs[0].Value != 1 {
+		t.Errorf("partial results = %+v, want call 0", rs)
+	}
+}
+
+func TestQuorumBadK(t *testing.T) {
+	for _, k := range []int{0, 3} {
+		if _, err := Quorum(context.Background(), k, after(0, 1, nil, nil), after(0, 2, nil, nil)); err == nil {
+			t.Errorf("Quorum(k=%d) of 2 calls = nil error", k)
+		}
+	}
+}
+
+func TestSettled(t *testing.T) {
+	boom := errors.New("boom")
+	rs := Settled(context.Background(),
+		after(10*time.Millisecond, 1, nil, nil),
+		after(0, 0, boom, nil),
+	)
+	if len(rs) != 2 || rs[0].Value != 1 || rs[0].Err != nil || rs[1].Err != boom {
+		t.Errorf("Settled = %+v", rs)
+	}
+}
+
+func TestParentCancellation(t *testing.T) {
+	stop := errors.New("stop")
+	ctx, cancel := context.WithCancelCause(context.Background())
+	time.AfterFunc(10*time.Millisecond, func() { cancel(stop) })
+	if _, err := All(ctx, after(5*time.Second, 1, nil, nil)); !errors.Is(err, stop) {
+		t.Errorf("All = %v, want the cause of ctx", err)
+	}
+}
@@ -0,0 +1,170 @@
+// Package consumer runs handlers over the messages of a queue.
+//
+// A Consumer fetches messages from a Broker and handles each one under its
+// own deadline. A message whose handler succeeds is acknowledged; one whose
+// handler fails or is cancelled is negatively acknowledged and redelivered
+// after a delay; one that keeps failing, or whose handler marks it as a
+// poison message, goes to the broker's dead-letter queue. When the
+// consumer's context ends it stops fetching and waits for the messages in
+// flight.
+//
+// Memory is a Broker for tests and single processes; File keeps the queue
+// in a directory so that it survives restarts.
+package consumer
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/surenraju-zz/go-context/safego"
+)
+
+// ErrShutdown is the cancellation cause of messages still in flight when
+// the ShutdownTimeout of a Consumer runs out.
+var ErrShutdown = errors.New("consumer: shut down")
+
+// Message is a message delivered by a Broker.
+type Message struct {
+	ID   string
+	Body []byte
+	// Attempts is the number of times the message has been delivered,
+	// including this one.
+	Attempts int
+}
+
+// Broker is a queue of messages. Implementations must be safe for
+// concurrent use.
+type Broker interface {
+	// Fetch blocks until a message is available, and hands it out to this
+	// caller only, or until ctx ends.
+	Fetch(ctx context.Context) (Message, error)
+	// Ack removes a fetched message from the queue.
+	Ack(ctx context.Context, id string) error
+	// Nack returns a fetched message to the queue, to be delivered again
+	// once delay has passed.
+	Nack(ctx context.Context, id string, delay time.Duration) error
+	// DeadLetter moves a fetched message to the dead-letter queue,
+	// recording why.
+	DeadLetter(ctx context.Context, m Message, reason error) error
+}
+
+// PoisonError marks an error that no redelivery can fix. A handler that
+// returns one sends its message straight to the dead-letter queue.
+type PoisonError struct {
+	Err error
+}
+
+func (e *PoisonError) Error() string { return "poison message: " + e.Err.Error() }
+
+func (e *PoisonError) Unwrap() error { return e.Err }
+
+// Poison wraps err in a *PoisonError.
+func Poison(err error) error {
+	return &PoisonError{Err: err}
+}
+
+// Handler processes one message. It should return promptly once ctx ends.
+type Handler func(ctx context.Context, m Message) error
+
+// Consumer runs a Handler over the messages of a Broker.
+type Consumer struct {
+	Broker  Broker
+	Handler Handler
+	// Concurrency is the number of messages handled at once. Zero means 1.
+	Concurrency int
+	// Timeout bounds the handling of each message. Zero means no limit.
+	Timeout time.Duration
+	// RetryDelay is how long a failed message waits before it is delivered
+	// again.
+	RetryDelay time.Duration
+	// MaxAttempts is the number of deliveries after which a failing
+	// message is dead-lettered. Zero means it is retried forever.
+	MaxAttempts int
+	// ShutdownTimeout bounds how long Run waits for the messages in flight
+	// once its context ends; they are then cancelled with ErrShutdown and
+	// redelivered. Zero means waiting for them to finish.
+	ShutdownTimeout time.Duration
+	// Logger receives a record for each failed message and broker error.
+	// Nil means slog.Default().
+	Logger *slog.Logger
+}
+
+func (c *Consumer) logger() *slog.Logger {
+	if c.Logger != nil {
+		return c.Logger
+	}
+	return slog.Default()
+}
+
+// Run fetches and handles messages until ctx ends, then waits for the
+// messages in flight and returns the cause of ctx. If fetching fails for
+// another reason, Run stops in the same way and returns that error.
+func (c *Consumer) Run(ctx context.Context) error {
+	// Messages in flight outlive ctx, so that shutting down does not abort
+	// them, unless ShutdownTimeout says otherwise.
+	work, stopWork := context.WithCancelCause(context.WithoutCancel(ctx))
+	defer stopWork(nil)
+	if c.ShutdownTimeout > 0 {
+		stop := context.AfterFunc(ctx, func() {
+			time.AfterFunc(c.ShutdownTimeout, func() { stopWork(ErrShutdown) })
+		})
+		defer stop()
+	}
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	sem := make(chan struct{}, max(c.Concurrency, 1))
+	for {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			return context.Cause(ctx)
+		}
+		m, err := c.Broker.Fetch(ctx)
+		if err != nil {
+			<-sem
+			if ctx.Err() != nil {
+				return context.Cause(ctx)
+			}
+			return err
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			c.handle(work, m)
+		}()
+	}
+}
+
+// handle runs the handler on m and settles m with the broker.
+func (c *Consumer) handle(work context.Context, m Message) {
+	ctx := work
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(work, c.Timeout)
+		defer cancel()
+	}
+	err := safego.Call(ctx, func(ctx context.Context) error { return c.Handler(ctx, m) })
+
+	// Settle the message even if the consumer is being shut down.
+	bctx := context.WithoutCancel(work)
+	log := c.logger().With(slog.String("id", m.ID), slog.Int("attempts", m.Attempts))
+	var poison *PoisonError
+	switch {
+	case err == nil:
+		err = c.Broker.Ack(bctx, m.ID)
+	case work.Err() == nil && (errors.As(err, &poison) || c.MaxAttempts > 0 && m.Attempts >= c.MaxAttempts):
+		log.Warn("consumer: dead-lettering message", slog.Any("error", err))
+		err = c.Broker.DeadLetter(bctx, m, err)
+	default:
+		log.Info("consumer: message failed, will retry", slog.Any("error", err), slog.Duration("delay", c.RetryDelay))
+		err = c.Broker.Nack(bctx, m.ID, c.RetryDelay)
+	}
+	if err != nil {
+		log.Error("consumer: broker error", slog.Any("error", err))
+	}
+}
@@ -0,0 +1,308 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+var discard = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// start runs c until the returned function is called, which returns the
+// result of Run.
+func start(t *testing.T, c *Consumer) func() error {
+	t.Helper()
+	if c.Logger == nil {
+		c.Logger = discard
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- c.Run(ctx) }()
+	stopped := false
+	stop := func() error {
+		t.Helper()
+		stopped = true
+		cancel()
+		select {
+		case err := <-done:
+			return err
+		case <-time.After(5 * time.Second):
+			t.Fatal("Run did not return")
+			return nil
+		}
+	}
+	t.Cleanup(func() {
+		if !stopped {
+			stop()
+		}
+	})
+	return stop
+}
+
+// eventually waits for cond to hold.
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestHandlesAndAcks(t *testing.T) {
+	b := NewMemory()
+	for _, body := range []string{"a", "b", "c"} {
+		b.Push([]byte(body))
+	}
+	got := make(chan string, 3)
+	stop := start(t, &Consumer{Broker: b, Concurrency: 2, Handler: func(_ context.Context, m Message) error {
+		got <- string(m.Body)
+		return nil
+	}})
+
+	seen := map[string]bool{}
+	for range 3 {
+		select {
+		case s := <-got:
+			seen[s] = true
+		case <-time.After(5 * time.Second):
+			t.Fatal("messages not handled")
+		}
+	}
+	if err := stop(); err != context.Canceled {
+		t.Errorf("Run = %v, want context.Canceled", err)
+	}
+	if len(seen) != 3 || b.Len() != 0 || len(b.Dead()) != 0 {
+		t.Errorf("handled %v, %d left, %d dead", seen, b.Len(), len(b.Dead()))
+	}
+	for _, id := range []string{"1", "2", "3"} {
+		if err := b.Ack(context.Background(), id); err != ErrUnknownMessage {
+			t.Errorf("message %s still in flight: %v", id, err)
+		}
+	}
+}
+
+func TestRedeliversFailedMessage(t *testing.T) {
+	b := NewMemory()
+	b.Push([]byte("x"))
+	attempts := make(chan int, 2)
+	start(t, &Consumer{Broker: b, RetryDelay: time.Millisecond, Handler: func(_ context.Context, m Message) error {
+		attempts <- m.Attempts
+		if m.Attempts == 1 {
+			return errors.New("transient")
+		}
+		return nil
+	}})
+	for want := 1; want <= 2; want++ {
+		select {
+		case got := <-attempts:
+			if got != want {
+				t.Errorf("delivery %d has Attempts %d", want, got)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("delivery %d not made", want)
+		}
+	}
+}
+
+func TestMaxAttemptsDeadLetters(t *testing.T) {
+	b := NewMemory()
+	b.Push([]byte("x"))
+	start(t, &Consumer{Broker: b, MaxAttempts: 3, Handler: func(context.Context, Message) error {
+		return errors.New("always fails")
+	}})
+	eventually(t, "dead letter", func() bool { return len(b.Dead()) == 1 })
+	d := b.Dead()[0]
+	if d.Message.Attempts != 3 || d.Reason != "always fails" {
+		t.Errorf("dead letter = %+v, want 3 attempts", d)
+	}
+}
+
+func TestPoisonDeadLettersAtOnce(t *testing.T) {
+	b := NewMemory()
+	b.Push([]byte("x"))
+	start(t, &Consumer{Broker: b, Handler: func(context.Context, Message) error {
+		return Poison(errors.New("malformed"))
+	}})
+	eventually(t, "dead letter", func() bool { return len(b.Dead()) == 1 })
+	d := b.Dead()[0]
+	if d.Message.Attempts != 1 || d.Reason != "poison message: malformed" {
+		t.Errorf("dead letter = %+v, want the first attempt", d)
+	}
+}
+
+func TestTimeoutCancelsHandler(t *testing.T) {
+	b := NewMemory()
+	b.Push([]byte("x"))
+	causes := make(chan error, 2)
+	start(t, &Consumer{Broker: b, Timeout: 10 * time.Millisecond, Handler: func(ctx context.Context, m Message) error {
+		if m.Attempts > 1 {
+			causes <- nil
+			return nil
+		}
+		<-ctx.Done()
+		causes <- context.Cause(ctx)
+		return ctx.Err()
+	}})
+	for _, want := range []error{context.DeadlineExceeded, nil} {
+		select {
+		case err := <-causes:
+			if err != want {
+				t.Errorf("handler saw %v, want %v", err, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("message not redelivered after timing out")
+		}
+	}
+}
+
+func TestShutdownWaitsForInFlight(t *testing.T) {
+	b := NewMemory()
+	b.Push([]byte("x"))
+	started, release := make(chan struct{}), make(chan struct{})
+	var handlerErr error
+	stop := start(t, &Consumer{Broker: b, Handler: func(ctx context.Context, _ Message) error {
+		close(started)
+		<-release
+		handlerErr = ctx.Err()
+		return nil
+	}})
+	<-started
+
+	time.AfterFunc(20*time.Millisecond, func() { close(release) })
+	begin := time.Now()
+	stop()
+	if d := time.Since(begin); d < 20*time.Millisecond {
+		t.Errorf("Run returned after %v, before the handler finished", d)
+	}
+	if handlerErr != nil {
+		t.Errorf("handler context ended on shutdown: %v", handlerErr)
+	}
+	if err := b.Ack(context.Background(), "1"); err != ErrUnknownMessage {
+		t.Errorf("message not acknowledged: %v", err)
+	}
+}
+
+func TestShutdownTimeoutCancelsInFlight(t *testing.T) {
+	b := NewMemory()
+	b.Push([]byte("x"))
+	started := make(chan struct{})
+	var cause error
+	stop := start(t, &Consumer{Broker: b, MaxAttempts: 1, ShutdownTimeout: 10 * time.Millisecond,
+		Handler: func(ctx context.Context, _ Message) error {
+			close(started)
+			<-ctx.Done()
+			cause = context.Cause(ctx)
+			return ctx.Err()
+		}})
+	<-started
+	stop()
+	if cause != ErrShutdown {
+		t.Errorf("handler cause = %v, want ErrShutdown", cause)
+	}
+	// Cancelled by the shutdown, the message is redelivered rather than
+	// dead-lettered, despite MaxAttempts.
+	eventually(t, "redelivery", func() bool { return b.Len() == 1 })
+	if len(b.Dead()) != 0 {
+		t.Errorf("dead letters = %+v, want none", b.Dead())
+	}
+}
+
+func TestHandlerPanicIsRetried(t *testing.T) {
+	b := NewMemory()
+	b.Push([]byte("x"))
+	attempts := make(chan int, 2)
+	start(t, &Consumer{Broker: b, Handler: func(_ context.Context, m Message) error {
+		attempts <- m.Attempts
+		if m.Attempts == 1 {
+			panic("oops")
+		}
+		return nil
+	}})
+	for want := 1; want <= 2; want++ {
+		select {
+		case got := <-attempts:
+			if got != want {
+				t.Errorf("delivery %d has Attempts %d", want, got)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("delivery %d not made", want)
+		}
+	}
+}
+
+func TestFile(t *testing.T) {
+	dir := t.TempDir()
+	b, err := NewFile(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.PollInterval = time.Millisecond
+	ctx := context.Background()
+	id, err := b.Push([]byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := b.Fetch(ctx)
+	if err != nil || m.ID != id || string(m.Body) != "hello" || m.Attempts != 1 {
+		t.Fatalf("Fetch = %+v, %v", m, err)
+	}
+	if err := b.Nack(ctx, id, 0); err != nil {
+		t.Fatal(err)
+	}
+	if m, err = b.Fetch(ctx); err != nil || m.Attempts != 2 {
+		t.Fatalf("Fetch after Nack = %+v, %v, want attempt 2", m, err)
+	}
+
+	// A restarted process recovers the messages left in flight.
+	b2, err := NewFile(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b2.Requeue(); err != nil {
+		t.Fatal(err)
+	}
+	if m, err = b2.Fetch(ctx); err != nil || m.Attempts != 3 {
+		t.Fatalf("Fetch after Requeue = %+v, %v, want attempt 3", m, err)
+	}
+	if err := b2.DeadLetter(ctx, m, errors.New("bad")); err != nil {
+		t.Fatal(err)
+	}
+	if err := b2.Ack(ctx, id); err != ErrUnknownMessage {
+		t.Errorf("Ack of a dead letter = %v, want ErrUnknownMessage", err)
+	}
+
+	tctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if _, err := b2.Fetch(tctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Fetch from an empty queue = %v, want the deadline", err)
+	}
+}
+
+func TestFileNackDelay(t *testing.T) {
+	b, err := NewFile(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.PollInterval = time.Millisecond
+	ctx := context.Background()
+	b.Push([]byte("x"))
+	m, err := b.Fetch(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.Nack(ctx, m.ID, 30*time.Millisecond)
+	begin := time.Now()
+	if _, err := b.Fetch(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(begin); d < 30*time.Millisecond {
+		t.Errorf("redelivered after %v, want at least the delay", d)
+	}
+}
@@ -0,0 +1,203 @@
+package consumer
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// File is a Broker that keeps its queue as one file per message in a
+// directory, so that messages survive restarts. Messages are claimed by
+// renaming their files, which makes it safe for several processes on the
+// same machine to consume the same directory.
+//
+// Under dir, ready holds the queued messages, inflight those being
+// handled, and dead the dead-lettered ones, each with a .reason file next
+// to it.
+type File struct {
+	// PollInterval is how often Fetch looks for new messages while the
+	// queue is empty. Zero means 100ms.
+	PollInterval time.Duration
+
+	dir string
+}
+
+// NewFile returns a File that keeps its queue in dir, creating it if
+// needed.
+func NewFile(dir string) (*File, error) {
+	for _, sub := range []string{"ready", "inflight", "dead"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o755); err != nil {
+			return nil, err
+		}
+	}
+	return &File{dir: dir}, nil
+}
+
+// A ready file is named <ready at>_<attempts>_<id>, with the time in Unix
+// nanoseconds padded so that names sort by it. An in-flight file is named
+// <id>_<attempts>.
+
+func readyName(at time.Time, attempts int, id string) string {
+	return fmt.Sprintf("%020d_%d_%s", at.UnixNano(), attempts, id)
+}
+
+func parseReady(name string) (at int64, attempts int, id string, ok bool) {
+	parts := strings.SplitN(name, "_", 3)
+	if len(parts) != 3 {
+		return 0, 0, "", false
+	}
+	at, err1 := strconv.ParseInt(parts[0], 10, 64)
+	attempts, err2 := strconv.Atoi(parts[1])
+	return at, attempts, parts[2], err1 == nil && err2 == nil
+}
+
+// Push adds a message with body to the queue and returns its ID.
+func (b *File) Push(body []byte) (string, error) {
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	now := time.Now()
+	id := strconv.FormatInt(now.UnixNano(), 36) + hex.EncodeToString(suffix)
+
+	// Write under another name first, so that Fetch never sees a partial
+	// message.
+	tmp := filepath.Join(b.dir, "."+id)
+	if err := os.WriteFile(tmp, body, 0o644); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp, filepath.Join(b.dir, "ready", readyName(now, 0, id))); err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+	return id, nil
+}
+
+// Fetch implements Broker.
+func (b *File) Fetch(ctx context.Context) (Message, error) {
+	poll := b.PollInterval
+	if poll <= 0 {
+		poll = 100 * time.Millisecond
+	}
+	for {
+		m, ok, err := b.claim()
+		if err != nil || ok {
+			return m, err
+		}
+		select {
+		case <-time.After(poll):
+		case <-ctx.Done():
+			return Message{}, context.Cause(ctx)
+		}
+	}
+}
+
+// claim moves the oldest ready message to inflight, if there is one.
+func (b *File) claim() (Message, bool, error) {
+	entries, err := os.ReadDir(filepath.Join(b.dir, "ready"))
+	if err != nil {
+		return Message{}, false, err
+	}
+	now := time.Now().UnixNano()
+	for _, e := range entries {
+		at, attempts, id, ok := parseReady(e.Name())
+		if !ok {
+			continue
+		}
+		if at > now {
+			break
+		}
+		attempts++
+		path := filepath.Join(b.dir, "inflight", id+"_"+strconv.Itoa(attempts))
+		if err := os.Rename(filepath.Join(b.dir, "ready", e.Name()), path); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				// Another consumer claimed it first.
+				continue
+			}
+			return Message{}, false, err
+		}
+		body, err := os.ReadFile(path)
+		if err != nil {
+			return Message{}, false, err
+		}
+		return Message{ID: id, Body: body, Attempts: attempts}, true, nil
+	}
+	return Message{}, false, nil
+}
+
+// inflight returns the path and attempts of the in-flight message id.
+func (b *File) inflight(id string) (string, int, error) {
+	matches, err := filepath.Glob(filepath.Join(b.dir, "inflight", id+"_*"))
+	if err != nil {
+		return "", 0, err
+	}
+	if len(matches) != 1 {
+		return "", 0, ErrUnknownMessage
+	}
+	attempts, err := strconv.Atoi(strings.TrimPrefix(filepath.Base(matches[0]), id+"_"))
+	if err != nil {
+		return "", 0, ErrUnknownMessage
+	}
+	return matches[0], attempts, nil
+}
+
+// Ack implements Broker.
+func (b *File) Ack(_ context.Context, id string) error {
+	path, _, err := b.inflight(id)
+	if err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// Nack implements Broker.
+func (b *File) Nack(_ context.Context, id string, delay time.Duration) error {
+	path, attempts, err := b.inflight(id)
+	if err != nil {
+		return err
+	}
+	return os.Rename(path, filepath.Join(b.dir, "ready", readyName(time.Now().Add(delay), attempts, id)))
+}
+
+// DeadLetter implements Broker.
+func (b *File) DeadLetter(_ context.Context, m Message, reason error) error {
+	path, _, err := b.inflight(m.ID)
+	if err != nil {
+		return err
+	}
+	dead := filepath.Join(b.dir, "dead", m.ID)
+	if err := os.WriteFile(dead+".reason", []byte(reason.Error()+"\n"), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(path, dead)
+}
+
+// Requeue returns every in-flight message to the queue, as if it had been
+// negatively acknowledged. Messages are left in flight by a process that
+// exits while handling them; call Requeue at startup, while no other
+// consumer is using dir, to recover them.
+func (b *File) Requeue() error {
+	entries, err := os.ReadDir(filepath.Join(b.dir, "inflight"))
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	var errs []error
+	for _, e := range entries {
+		id, n, ok := strings.Cut(e.Name(), "_")
+		attempts, err := strconv.Atoi(n)
+		if !ok || err != nil {
+			continue
+		}
+		err = os.Rename(filepath.Join(b.dir, "inflight", e.Name()), filepath.Join(b.dir, "ready", readyName(now, attempts, id)))
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
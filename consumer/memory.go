@@ -0,0 +1,133 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrUnknownMessage is returned when acknowledging a message that is not in
+// flight.
+var ErrUnknownMessage = errors.New("consumer: unknown message")
+
+// DeadLetter is a message in a dead-letter queue.
+type DeadLetter struct {
+	Message Message
+	Reason  string
+}
+
+// Memory is a Broker that keeps its queue in memory.
+type Memory struct {
+	mu       sync.Mutex
+	seq      int
+	ready    []Message
+	inflight map[string]Message
+	dead     []DeadLetter
+	// changed is closed and replaced whenever a message becomes ready.
+	changed chan struct{}
+}
+
+// NewMemory returns an empty Memory.
+func NewMemory() *Memory {
+	return &Memory{inflight: make(map[string]Message), changed: make(chan struct{})}
+}
+
+// Push adds a message with body to the queue and returns its ID.
+func (b *Memory) Push(body []byte) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.seq++
+	id := strconv.Itoa(b.seq)
+	b.enqueue(Message{ID: id, Body: body})
+	return id
+}
+
+// enqueue must be called with mu held.
+func (b *Memory) enqueue(m Message) {
+	b.ready = append(b.ready, m)
+	close(b.changed)
+	b.changed = make(chan struct{})
+}
+
+// Fetch implements Broker.
+func (b *Memory) Fetch(ctx context.Context) (Message, error) {
+	for {
+		b.mu.Lock()
+		if len(b.ready) > 0 {
+			m := b.ready[0]
+			b.ready = b.ready[1:]
+			m.Attempts++
+			b.inflight[m.ID] = m
+			b.mu.Unlock()
+			return m, nil
+		}
+		changed := b.changed
+		b.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return Message{}, context.Cause(ctx)
+		}
+	}
+}
+
+// take removes the in-flight message id.
+func (b *Memory) take(id string) (Message, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	m, ok := b.inflight[id]
+	if !ok {
+		return Message{}, ErrUnknownMessage
+	}
+	delete(b.inflight, id)
+	return m, nil
+}
+
+// Ack implements Broker.
+func (b *Memory) Ack(_ context.Context, id string) error {
+	_, err := b.take(id)
+	return err
+}
+
+// Nack implements Broker.
+func (b *Memory) Nack(_ context.Context, id string, delay time.Duration) error {
+	m, err := b.take(id)
+	if err != nil {
+		return err
+	}
+	time.AfterFunc(delay, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.enqueue(m)
+	})
+	return nil
+}
+
+// DeadLetter implements Broker.
+func (b *Memory) DeadLetter(_ context.Context, m Message, reason error) error {
+	if _, err := b.take(m.ID); err != nil {
+		return err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.dead = append(b.dead, DeadLetter{Message: m, Reason: reason.Error()})
+	return nil
+}
+
+// Len returns the number of messages ready to be delivered. Messages
+// waiting out a redelivery delay are not counted.
+func (b *Memory) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.ready)
+}
+
+// Dead returns the messages in the dead-letter queue.
+func (b *Memory) Dead() []DeadLetter {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]DeadLetter(nil), b.dead...)
+}
@@ -0,0 +1,206 @@
+// Package debounce coalesces bursts of calls into fewer executions.
+//
+// A Debouncer runs its function once calls have stopped arriving for a
+// while; a Throttler runs it at most once per interval, keeping the latest
+// call for the end of the interval. Either way the execution runs under a
+// context that carries the values of the latest caller's context, and is
+// cancelled with ErrSuperseded as soon as a newer call replaces it, or with
+// the cause of the owning context when that ends.
+package debounce
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrSuperseded is the cancellation cause of an execution replaced by a
+// later call.
+var ErrSuperseded = errors.New("debounce: superseded by a later call")
+
+// invocation is an execution, pending or running, requested by a call.
+type invocation struct {
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+}
+
+// newInvocation returns an invocation with the values of caller's context
+// but not its lifetime: the call that triggers it usually returns long
+// before it runs.
+func newInvocation(caller context.Context) *invocation {
+	ctx, cancel := context.WithCancelCause(context.WithoutCancel(caller))
+	return &invocation{ctx: ctx, cancel: cancel}
+}
+
+// Debouncer runs a function once calls to it have stopped for a while. It
+// is safe for concurrent use.
+type Debouncer struct {
+	owner context.Context
+	wait  time.Duration
+	fn    func(ctx context.Context)
+
+	mu    sync.Mutex
+	timer *time.Timer
+	due   time.Time
+	// latest is the invocation of the latest call, pending or running.
+	latest *invocation
+	next   *invocation
+}
+
+// NewDebouncer returns a Debouncer that runs fn wait after the last of a
+// burst of calls. When owner ends, the pending or running execution is
+// cancelled with its cause and later calls are ignored.
+func NewDebouncer(owner context.Context, wait time.Duration, fn func(ctx context.Context)) *Debouncer {
+	d := &Debouncer{owner: owner, wait: wait, fn: fn}
+	context.AfterFunc(owner, d.shutdown)
+	return d
+}
+
+// Call requests an execution on behalf of the caller's ctx. The execution
+// requested by the previous call, if still pending or running, is
+// cancelled with ErrSuperseded.
+func (d *Debouncer) Call(ctx context.Context) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.owner.Err() != nil {
+		return
+	}
+	if d.latest != nil {
+		d.latest.cancel(ErrSuperseded)
+	}
+	d.latest = newInvocation(ctx)
+	d.next = d.latest
+	d.due = time.Now().Add(d.wait)
+	if d.timer == nil {
+		d.timer = time.AfterFunc(d.wait, d.fire)
+	} else {
+		d.timer.Reset(d.wait)
+	}
+}
+
+func (d *Debouncer) fire() {
+	d.mu.Lock()
+	// A call may have pushed the execution back after this timer fired.
+	if d.next == nil || time.Now().Before(d.due) {
+		d.mu.Unlock()
+		return
+	}
+	inv := d.next
+	d.next = nil
+	d.mu.Unlock()
+
+	if inv.ctx.Err() == nil {
+		d.fn(inv.ctx)
+	}
+	inv.cancel(context.Canceled)
+}
+
+func (d *Debouncer) shutdown() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.timer != nil {
+		d.timer.Stop()
+	}
+	if d.latest != nil {
+		d.latest.cancel(context.Cause(d.owner))
+	}
+	d.next = nil
+}
+
+// Throttler runs a function at most once per interval. It is safe for
+// concurrent use.
+type Throttler struct {
+	owner    context.Context
+	interval time.Duration
+	fn       func(ctx context.Context)
+
+	mu        sync.Mutex
+	last      time.Time
+	timer     *time.Timer
+	scheduled bool
+	running   *invocation
+	next      *invocation
+}
+
+// NewThrottler returns a Throttler that runs fn at most once per interval,
+// and never twice at once. When owner ends, the pending or running
+// execution is cancelled with its cause and later calls are ignored.
+func NewThrottler(owner context.Context, interval time.Duration, fn func(ctx context.Context)) *Throttler {
+	t := &Throttler{owner: owner, interval: interval, fn: fn}
+	context.AfterFunc(owner, t.shutdown)
+	return t
+}
+
+// Call requests an execution on behalf of the caller's ctx. It runs at
+// once if the interval since the last execution has passed; otherwise it
+// replaces any execution still waiting for the interval to end, cancelling
+// it with ErrSuperseded. A running execution is not interrupted.
+func (t *Throttler) Call(ctx context.Context) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.owner.Err() != nil {
+		return
+	}
+	if t.next != nil {
+		t.next.cancel(ErrSuperseded)
+	}
+	t.next = newInvocation(ctx)
+	t.schedule()
+}
+
+// schedule starts the next execution, or arranges for it to start, once
+// the interval has passed and nothing is running; t.mu must be held.
+func (t *Throttler) schedule() {
+	if t.next == nil || t.running != nil || t.scheduled {
+		return
+	}
+	if wait := time.Until(t.last.Add(t.interval)); wait > 0 {
+		t.scheduled = true
+		if t.timer == nil {
+			t.timer = time.AfterFunc(wait, t.fire)
+		} else {
+			t.timer.Reset(wait)
+		}
+		return
+	}
+	inv := t.next
+	t.next = nil
+	t.running = inv
+	t.last = time.Now()
+	go func() {
+		t.fn(inv.ctx)
+		inv.cancel(context.Canceled)
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		t.running = nil
+		if t.owner.Err() == nil {
+			t.schedule()
+		}
+	}()
+}
+
+func (t *Throttler) fire() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.scheduled = false
+	if t.owner.Err() == nil {
+		t.schedule()
+	}
+}
+
+func (t *Throttler) shutdown() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.timer != nil {
+		t.timer.Stop()
+	}
+	cause := context.Cause(t.owner)
+	if t.running != nil {
+		t.running.cancel(cause)
+	}
+	if t.next != nil {
+		t.next.cancel(cause)
+		t.next = nil
+	}
+}
@@ -0,0 +1,210 @@
+package debounce
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type key struct{}
+
+// with returns a caller context carrying v.
+func with(v int) context.Context {
+	return context.WithValue(context.Background(), key{}, v)
+}
+
+// run is an execution seen by a test function.
+type run struct {
+	v  int
+	at time.Time
+}
+
+// recorder returns a function that reports each execution on a channel.
+func recorder() (func(ctx context.Context), <-chan run) {
+	runs := make(chan run, 16)
+	return func(ctx context.Context) {
+		v, _ := ctx.Value(key{}).(int)
+		runs <- run{v: v, at: time.Now()}
+	}, runs
+}
+
+func next(t *testing.T, runs <-chan run) run {
+	t.Helper()
+	select {
+	case r := <-runs:
+		return r
+	case <-time.After(5 * time.Second):
+		t.Fatal("function not run")
+		return run{}
+	}
+}
+
+func none(t *testing.T, runs <-chan run, wait time.Duration) {
+	t.Helper()
+	select {
+	case r := <-runs:
+		t.Errorf("unexpected execution for %d", r.v)
+	case <-time.After(wait):
+	}
+}
+
+func TestDebouncerRunsLastOfBurst(t *testing.T) {
+	fn, runs := recorder()
+	d := NewDebouncer(context.Background(), 20*time.Millisecond, fn)
+	var last time.Time
+	for i := 1; i <= 5; i++ {
+		d.Call(with(i))
+		last = time.Now()
+		time.Sleep(2 * time.Millisecond)
+	}
+	r := next(t, runs)
+	if r.v != 5 {
+		t.Errorf("ran for call %d, want the last", r.v)
+	}
+	if gap := r.at.Sub(last); gap < 20*time.Millisecond {
+		t.Errorf("ran %v after the last call, want the wait", gap)
+	}
+	none(t, runs, 40*time.Millisecond)
+}
+
+func TestDebouncerSupersedesRunning(t *testing.T) {
+	started := make(chan struct{}, 2)
+	causes := make(chan error, 2)
+	d := NewDebouncer(context.Background(), time.Millisecond, func(ctx context.Context) {
+		started <- struct{}{}
+		if ctx.Value(key{}) == 1 {
+			<-ctx.Done()
+		}
+		causes <- context.Cause(ctx)
+	})
+	d.Call(with(1))
+	<-started
+	d.Call(with(2))
+
+	if err := <-causes; err != ErrSuperseded {
+		t.Errorf("first execution cause = %v, want ErrSuperseded", err)
+	}
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("superseding call not run")
+	}
+	if err := <-causes; err != nil {
+		t.Errorf("second execution cancelled: %v", err)
+	}
+}
+
+func TestDebouncerOwnerEnds(t *testing.T) {
+	stop := errors.New("stop")
+	owner, cancel := context.WithCancelCause(context.Background())
+	fn, runs := recorder()
+	d := NewDebouncer(owner, 20*time.Millisecond, fn)
+	d.Call(with(1))
+	cancel(stop)
+	d.Call(with(2))
+	none(t, runs, 40*time.Millisecond)
+}
+
+func TestDebouncerOwnerCancelsRunning(t *testing.T) {
+	stop := errors.New("stop")
+	owner, cancel := context.WithCancelCause(context.Background())
+	started, cause := make(chan struct{}), make(chan error, 1)
+	d := NewDebouncer(owner, time.Millisecond, func(ctx context.Context) {
+		close(started)
+		<-ctx.Done()
+		cause <- context.Cause(ctx)
+	})
+	d.Call(context.Background())
+	<-started
+	cancel(stop)
+	if err := <-cause; err != stop {
+		t.Errorf("cause = %v, want the owner's", err)
+	}
+}
+
+func TestThrottlerLeadingAndTrailing(t *testing.T) {
+	fn, runs := recorder()
+	th := NewThrottler(context.Background(), 30*time.Millisecond, fn)
+	th.Call(with(1))
+	first := next(t, runs)
+	if first.v != 1 {
+		t.Fatalf("ran for call %d, want the first at once", first.v)
+	}
+	for i := 2; i <= 4; i++ {
+		th.Call(with(i))
+	}
+	second := next(t, runs)
+	if second.v != 4 {
+		t.Errorf("ran for call %d, want the latest", second.v)
+	}
+	if gap := second.at.Sub(first.at); gap < 30*time.Millisecond {
+		t.Errorf("executions %v apart, want at least the interval", gap)
+	}
+	none(t, runs, 50*time.Millisecond)
+}
+
+func TestThrottlerSupersedesWaiting(t *testing.T) {
+	causes := make(chan error, 3)
+	th := NewThrottler(context.Background(), 30*time.Millisecond, func(ctx context.Context) {
+		causes <- context.Cause(ctx)
+	})
+	th.Call(with(1))
+	<-causes
+
+	th.Call(with(2))
+	th.mu.Lock()
+	waiting := th.next
+	th.mu.Unlock()
+	th.Call(with(3))
+	if err := context.Cause(waiting.ctx); err != ErrSuperseded {
+		t.Errorf("replaced execution cause = %v, want ErrSuperseded", err)
+	}
+	if err := <-causes; err != nil {
+		t.Errorf("trailing execution cancelled: %v", err)
+	}
+}
+
+func TestThrottlerDoesNotInterruptRunning(t *testing.T) {
+	release := make(chan struct{})
+	done := make(chan error, 2)
+	th := NewThrottler(context.Background(), time.Millisecond, func(ctx context.Context) {
+		if ctx.Value(key{}) == 1 {
+			<-release
+		}
+		done <- ctx.Err()
+	})
+	th.Call(with(1))
+	time.Sleep(10 * time.Millisecond)
+	th.Call(with(2))
+	close(release)
+	for range 2 {
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Errorf("execution cancelled: %v", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("execution not run")
+		}
+	}
+}
+
+func TestThrottlerOwnerEnds(t *testing.T) {
+	stop := errors.New("stop")
+	owner, cancel := context.WithCancelCause(context.Background())
+	started, cause := make(chan struct{}), make(chan error, 1)
+	th := NewThrottler(owner, time.Hour, func(ctx context.Context) {
+		close(started)
+		<-ctx.Done()
+		cause <- context.Cause(ctx)
+	})
+	th.Call(context.Background())
+	<-started
+	th.Call(context.Background())
+	cancel(stop)
+	if err := <-cause; err != stop {
+		t.Errorf("cause = %v, want the owner's", err)
+	}
+	th.Call(context.Background())
+}
@@ -0,0 +1,136 @@
+// Package distcancel carries cancellation signals across process boundaries.
+//
+// A worker registers a context under a distributed ID; a coordinator in
+// another process cancels that ID, and the cancellation travels over a
+// pluggable Transport to every process that registered it.
+package distcancel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrTransportClosed is returned by Run when the transport stops delivering
+// cancellations before ctx ends.
+var ErrTransportClosed = errors.New("distcancel: transport closed")
+
+// Cancellation asks every process to cancel the contexts registered under ID.
+type Cancellation struct {
+	ID    string `json:"id"`
+	Cause string `json:"cause,omitempty"`
+}
+
+// Transport delivers cancellations between processes.
+type Transport interface {
+	// Publish sends c to every process subscribed to the transport,
+	// including the sender.
+	Publish(ctx context.Context, c Cancellation) error
+	// Subscribe returns a channel of published cancellations. The channel
+	// is closed when ctx ends or the transport fails.
+	Subscribe(ctx context.Context) (<-chan Cancellation, error)
+}
+
+// RemoteCancelError is the cause of a context cancelled through a Registry.
+type RemoteCancelError struct {
+	ID    string
+	Cause string
+}
+
+func (e *RemoteCancelError) Error() string {
+	if e.Cause == "" {
+		return fmt.Sprintf("distcancel: %s cancelled remotely", e.ID)
+	}
+	return fmt.Sprintf("distcancel: %s cancelled remotely: %s", e.ID, e.Cause)
+}
+
+type entry struct {
+	cancel context.CancelCauseFunc
+}
+
+// Registry maps distributed IDs to local contexts.
+type Registry struct {
+	transport Transport
+
+	mu      sync.Mutex
+	entries map[string]map[*entry]struct{}
+}
+
+// NewRegistry returns a Registry that sends and receives cancellations
+// through t. Call Run to start receiving.
+func NewRegistry(t Transport) *Registry {
+	return &Registry{
+		transport: t,
+		entries:   make(map[string]map[*entry]struct{}),
+	}
+}
+
+// Register returns a context derived from ctx that is also cancelled when a
+// cancellation for id arrives from any process. Several contexts may share
+// an id. The returned CancelFunc releases the registration and should be
+// called as soon as the work is done.
+func (r *Registry) Register(ctx context.Context, id string) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	e := &entry{cancel: cancel}
+
+	r.mu.Lock()
+	if r.entries[id] == nil {
+		r.entries[id] = make(map[*entry]struct{})
+	}
+	r.entries[id][e] = struct{}{}
+	r.mu.Unlock()
+
+	// Drop the registration however the context ends, so the map does not
+	// grow with work that finished without calling the CancelFunc.
+	stop := context.AfterFunc(ctx, func() { r.remove(id, e) })
+	return ctx, func() {
+		stop()
+		r.remove(id, e)
+		cancel(context.Canceled)
+	}
+}
+
+func (r *Registry) remove(id string, e *entry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.entries[id], e)
+	if len(r.entries[id]) == 0 {
+		delete(r.entries, id)
+	}
+}
+
+// Cancel publishes a cancellation for id. Contexts registered under id in
+// this process are cancelled once the transport echoes it back to Run.
+func (r *Registry) Cancel(ctx context.Context, id, cause string) error {
+	return r.transport.Publish(ctx, Cancellation{ID: id, Cause: cause})
+}
+
+// Run receives cancellations from the transport and cancels the matching
+// contexts until ctx ends or the subscription closes.
+func (r *Registry) Run(ctx context.Context) error {
+	ch, err := r.transport.Subscribe(ctx)
+	if err != nil {
+		return err
+	}
+	for c := range ch {
+		r.dispatch(c)
+	}
+	if ctx.Err() != nil {
+		return context.Cause(ctx)
+	}
+	return ErrTransportClosed
+}
+
+func (r *Registry) dispatch(c Cancellation) {
+	r.mu.Lock()
+	var cancels []context.CancelCauseFunc
+	for e := range r.entries[c.ID] {
+		cancels = append(cancels, e.cancel)
+	}
+	r.mu.Unlock()
+
+	for _, cancel := range cancels {
+		cancel(&RemoteCancelError{ID: c.ID, Cause: c.Cause})
+	}
+}
@@ -0,0 +1,178 @@
+package distcancel
+
+import (
+	"context"
+	"errors"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// startBroker serves a Broker on a socket in a temporary directory until
+// the test ends.
+func startBroker(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "cancel.sock")
+	b, err := ListenBroker(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		b.Serve(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return path
+}
+
+// startRegistry connects a Registry to the broker at path and runs it until
+// the test ends.
+func startRegistry(t *testing.T, path string) *Registry {
+	t.Helper()
+	tr, err := DialUnix(context.Background(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := NewRegistry(tr)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		r.Run(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		tr.Close()
+		<-done
+	})
+	return r
+}
+
+// waitDone fails the test if ctx is not cancelled in time.
+func waitDone(t *testing.T, ctx context.Context) {
+	t.Helper()
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("context not cancelled")
+	}
+}
+
+func TestCancelAcrossRegistries(t *testing.T) {
+	path := startBroker(t)
+	worker := startRegistry(t, path)
+	coordinator := startRegistry(t, path)
+
+	job, release := worker.Register(context.Background(), "job-1")
+	defer release()
+	other, releaseOther := worker.Register(context.Background(), "job-2")
+	defer releaseOther()
+
+	// Subscriptions are set up asynchronously; keep publishing until the
+	// cancellation arrives.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for job.Err() == nil && ctx.Err() == nil {
+		if err := coordinator.Cancel(ctx, "job-1", "user request"); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	waitDone(t, job)
+
+	var rc *RemoteCancelError
+	if !errors.As(context.Cause(job), &rc) {
+		t.Fatalf("cause = %v, want *RemoteCancelError", context.Cause(job))
+	}
+	if rc.ID != "job-1" || rc.Cause != "user request" {
+		t.Errorf("cause = %+v", rc)
+	}
+	if other.Err() != nil {
+		t.Errorf("job-2 cancelled: %v", context.Cause(other))
+	}
+}
+
+func TestCancelReachesEveryContextWithID(t *testing.T) {
+	path := startBroker(t)
+	r := startRegistry(t, path)
+
+	a, releaseA := r.Register(context.Background(), "shared")
+	defer releaseA()
+	b, releaseB := r.Register(context.Background(), "shared")
+	defer releaseB()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for (a.Err() == nil || b.Err() == nil) && ctx.Err() == nil {
+		r.Cancel(ctx, "shared", "")
+		time.Sleep(10 * time.Millisecond)
+	}
+	waitDone(t, a)
+	waitDone(t, b)
+}
+
+func TestReleaseRemovesRegistration(t *testing.T) {
+	r := NewRegistry(nil)
+	ctx, release := r.Register(context.Background(), "job")
+	release()
+	if !errors.Is(context.Cause(ctx), context.Canceled) {
+		t.Errorf("cause = %v, want context.Canceled", context.Cause(ctx))
+	}
+	r.mu.Lock()
+	n := len(r.entries)
+	r.mu.Unlock()
+	if n != 0 {
+		t.Errorf("%d ids still registered", n)
+	}
+}
+
+func TestParentCancelRemovesRegistration(t *testing.T) {
+	r := NewRegistry(nil)
+	parent, cancel := context.WithCancel(context.Background())
+	_, release := r.Register(parent, "job")
+	defer release()
+	cancel()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		r.mu.Lock()
+		n := len(r.entries)
+		r.mu.Unlock()
+		if n == 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("registration not removed after parent ended")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestRunReturnsWhenTransportCloses(t *testing.T) {
+	path := startBroker(t)
+	tr, err := DialUnix(context.Background(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := NewRegistry(tr)
+	errc := make(chan error, 1)
+	go func() { errc <- r.Run(context.Background()) }()
+
+	time.Sleep(10 * time.Millisecond)
+	tr.Close()
+	select {
+	case err := <-errc:
+		// Depending on timing the subscription fails or ends.
+		if !errors.Is(err, ErrTransportClosed) && !errors.Is(err, net.ErrClosed) {
+			t.Errorf("Run = %v, want ErrTransportClosed", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after the transport closed")
+	}
+}
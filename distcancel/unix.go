@@ -0,0 +1,205 @@
+package distcancel
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// writeTimeout bounds how long the broker waits on a single slow client
+// before dropping it.
+const writeTimeout = time.Second
+
+// Broker relays cancellations between processes connected to a Unix-domain
+// socket. Every cancellation received from one client is written to all
+// connected clients, the sender included.
+type Broker struct {
+	ln net.Listener
+
+	mu    sync.Mutex
+	conns map[net.Conn]struct{}
+}
+
+// ListenBroker listens on the Unix-domain socket at path.
+func ListenBroker(path string) (*Broker, error) {
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	return &Broker{ln: ln, conns: make(map[net.Conn]struct{})}, nil
+}
+
+// Serve accepts clients until ctx ends, then closes the socket and every
+// client connection.
+func (b *Broker) Serve(ctx context.Context) error {
+	stop := context.AfterFunc(ctx, func() {
+		b.ln.Close()
+		b.mu.Lock()
+		for c := range b.conns {
+			c.Close()
+		}
+		b.mu.Unlock()
+	})
+	defer stop()
+
+	for {
+		conn, err := b.ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return context.Cause(ctx)
+			}
+			return err
+		}
+		b.mu.Lock()
+		b.conns[conn] = struct{}{}
+		b.mu.Unlock()
+		go b.serveConn(conn)
+	}
+}
+
+func (b *Broker) serveConn(conn net.Conn) {
+	defer b.drop(conn)
+	sc := bufio.NewScanner(conn)
+	for sc.Scan() {
+		var c Cancellation
+		if err := json.Unmarshal(sc.Bytes(), &c); err != nil || c.ID == "" {
+			continue
+		}
+		b.broadcast(sc.Bytes())
+	}
+}
+
+func (b *Broker) broadcast(line []byte) {
+	msg := append(append([]byte(nil), line...), '\n')
+
+	b.mu.Lock()
+	conns := make([]net.Conn, 0, len(b.conns))
+	for c := range b.conns {
+		conns = append(conns, c)
+	}
+	b.mu.Unlock()
+
+	for _, c := range conns {
+		c.SetWriteDeadline(time.Now().Add(writeTimeout))
+		if _, err := c.Write(msg); err != nil {
+			b.drop(c)
+		}
+	}
+}
+
+func (b *Broker) drop(conn net.Conn) {
+	b.mu.Lock()
+	delete(b.conns, conn)
+	b.mu.Unlock()
+	conn.Close()
+}
+
+// UnixTransport is a Transport connected to a Broker.
+type UnixTransport struct {
+	conn net.Conn
+	wmu  sync.Mutex
+
+	mu     sync.Mutex
+	subs   map[*subscriber]struct{}
+	closed bool
+}
+
+type subscriber struct {
+	ch   chan Cancellation
+	done chan struct{}
+	once sync.Once
+}
+
+// DialUnix connects to the Broker listening at path.
+func DialUnix(ctx context.Context, path string) (*UnixTransport, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "unix", path)
+	if err != nil {
+		return nil, err
+	}
+	t := &UnixTransport{conn: conn, subs: make(map[*subscriber]struct{})}
+	go t.read()
+	return t, nil
+}
+
+// Publish implements Transport.
+func (t *UnixTransport) Publish(ctx context.Context, c Cancellation) error {
+	if c.ID == "" {
+		return errors.New("distcancel: empty id")
+	}
+	msg, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	msg = append(msg, '\n')
+
+	t.wmu.Lock()
+	defer t.wmu.Unlock()
+	if d, ok := ctx.Deadline(); ok {
+		t.conn.SetWriteDeadline(d)
+		defer t.conn.SetWriteDeadline(time.Time{})
+	}
+	_, err = t.conn.Write(msg)
+	return err
+}
+
+// Subscribe implements Transport.
+func (t *UnixTransport) Subscribe(ctx context.Context) (<-chan Cancellation, error) {
+	s := &subscriber{ch: make(chan Cancellation, 16), done: make(chan struct{})}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return nil, net.ErrClosed
+	}
+	t.subs[s] = struct{}{}
+	context.AfterFunc(ctx, func() { t.unsubscribe(s) })
+	return s.ch, nil
+}
+
+func (t *UnixTransport) unsubscribe(s *subscriber) {
+	// Closing done first releases the reader if it is blocked sending to
+	// s while holding t.mu.
+	s.once.Do(func() { close(s.done) })
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.subs[s]; ok {
+		delete(t.subs, s)
+		close(s.ch)
+	}
+}
+
+func (t *UnixTransport) read() {
+	sc := bufio.NewScanner(t.conn)
+	for sc.Scan() {
+		var c Cancellation
+		if err := json.Unmarshal(sc.Bytes(), &c); err != nil {
+			continue
+		}
+		t.mu.Lock()
+		for s := range t.subs {
+			select {
+			case s.ch <- c:
+			case <-s.done:
+			}
+		}
+		t.mu.Unlock()
+	}
+
+	t.mu.Lock()
+	t.closed = true
+	for s := range t.subs {
+		delete(t.subs, s)
+		close(s.ch)
+	}
+	t.mu.Unlock()
+}
+
+// Close disconnects from the broker and closes all subscriptions.
+func (t *UnixTransport) Close() error {
+	return t.conn.Close()
+}
@@ -0,0 +1,169 @@
+// Package election elects a single leader among candidates that share a
+// lease.Holder.
+//
+// Campaign blocks until the candidate holds the election's lease and then
+// returns a leadership context, which is cancelled when leadership ends:
+// with a *lease.LostError if the lease could not be renewed, or with
+// ErrResigned after Resign. Work done as leader runs under that context, in
+// the same way as any other cancellable operation.
+//
+// Any lease.Holder can back an election; lease.File elects a leader among
+// processes on one machine.
+package election
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/surenraju-zz/go-context/lease"
+)
+
+var (
+	// ErrResigned is the cancellation cause of a leadership context ended
+	// by Resign.
+	ErrResigned = errors.New("election: resigned")
+	// ErrLeader is returned by Campaign when the candidate is already the
+	// leader.
+	ErrLeader = errors.New("election: already leader")
+)
+
+type options struct {
+	id    string
+	retry time.Duration
+}
+
+// Option configures an Election.
+type Option func(*options)
+
+// ID sets the identity of the candidate, used as the lease owner. By
+// default each term gets a unique identity from the lease package.
+func ID(id string) Option {
+	return func(o *options) { o.id = id }
+}
+
+// RetryEvery sets how often Campaign tries to take the lease while another
+// candidate holds it. The default is half the ttl; non-positive values
+// select it.
+func RetryEvery(d time.Duration) Option {
+	return func(o *options) { o.retry = d }
+}
+
+// Election is one candidate's view of an election. It is safe for
+// concurrent use.
+type Election struct {
+	holder lease.Holder
+	name   string
+	ttl    time.Duration
+	opts   options
+
+	// campaign serializes calls to Campaign; mu guards term.
+	campaign sync.Mutex
+	mu       sync.Mutex
+	term     *term
+}
+
+// term is a period of leadership.
+type term struct {
+	lease  *lease.Lease
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+}
+
+// New returns a candidate in the election called name, decided by the
+// lease on name in h. The leader's lease lasts ttl without renewal, which
+// bounds how long the others wait after a leader disappears.
+func New(h lease.Holder, name string, ttl time.Duration, opts ...Option) *Election {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.retry <= 0 {
+		o.retry = max(ttl/2, 1)
+	}
+	return &Election{holder: h, name: name, ttl: ttl, opts: o}
+}
+
+// Campaign blocks until the candidate is elected, then returns a context
+// derived from ctx that stays alive for as long as it leads. If ctx ends
+// first, Campaign returns its cause. Errors from the Holder other than
+// lease.ErrHeld end the campaign, as does a non-positive ttl, reported as
+// lease.ErrInvalidDuration.
+func (e *Election) Campaign(ctx context.Context) (context.Context, error) {
+	e.campaign.Lock()
+	defer e.campaign.Unlock()
+	if e.Leading() {
+		return nil, ErrLeader
+	}
+
+	var leaseOpts []lease.Option
+	if e.opts.id != "" {
+		leaseOpts = append(leaseOpts, lease.Owner(e.opts.id))
+	}
+	t := time.NewTicker(e.opts.retry)
+	defer t.Stop()
+	for {
+		if ctx.Err() != nil {
+			return nil, context.Cause(ctx)
+		}
+		leaseCtx, l, err := lease.Acquire(ctx, e.holder, e.name, e.ttl, leaseOpts...)
+		if err == nil {
+			if ctx.Err() != nil {
+				// ctx ended while the Holder was granting the lease.
+				l.Release()
+				return nil, context.Cause(ctx)
+			}
+			return e.start(leaseCtx, l), nil
+		}
+		if !errors.Is(err, lease.ErrHeld) {
+			return nil, err
+		}
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			return nil, context.Cause(ctx)
+		}
+	}
+}
+
+// start begins a term and returns its context.
+func (e *Election) start(leaseCtx context.Context, l *lease.Lease) context.Context {
+	ctx, cancel := context.WithCancelCause(leaseCtx)
+	t := &term{lease: l, ctx: ctx, cancel: cancel}
+	e.mu.Lock()
+	e.term = t
+	e.mu.Unlock()
+	// However the term ends, give up the lease and let the candidate
+	// campaign again.
+	context.AfterFunc(ctx, func() {
+		l.Release()
+		e.mu.Lock()
+		if e.term == t {
+			e.term = nil
+		}
+		e.mu.Unlock()
+	})
+	return ctx
+}
+
+// Leading reports whether the candidate is currently the leader.
+func (e *Election) Leading() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.term != nil && e.term.ctx.Err() == nil
+}
+
+// Resign ends the current term, if any: the leadership context is
+// cancelled with ErrResigned and the lease is released, so that another
+// candidate can be elected. It returns the error from releasing the lease.
+func (e *Election) Resign() error {
+	e.mu.Lock()
+	t := e.term
+	e.mu.Unlock()
+	if t == nil {
+		return nil
+	}
+	t.cancel(ErrResigned)
+	return t.lease.Release()
+}
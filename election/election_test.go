@@ -0,0 +1,169 @@
+package election
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/surenraju-zz/go-context/lease"
+)
+
+func waitCause(t *testing.T, ctx context.Context) error {
+	t.Helper()
+	select {
+	case <-ctx.Done():
+		return context.Cause(ctx)
+	case <-time.After(5 * time.Second):
+		t.Fatal("leadership context not cancelled")
+		return nil
+	}
+}
+
+// campaign runs Campaign in the background and returns its results.
+func campaign(ctx context.Context, e *Election) <-chan error {
+	done := make(chan error, 1)
+	go func() {
+		_, err := e.Campaign(ctx)
+		done <- err
+	}()
+	return done
+}
+
+func TestElectedWithFreeLease(t *testing.T) {
+	e := New(lease.NewMemory(), "leader", time.Second, ID("a"))
+	ctx, err := e.Campaign(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer e.Resign()
+	if ctx.Err() != nil || !e.Leading() {
+		t.Fatal("not leading after Campaign")
+	}
+	if _, err := e.Campaign(context.Background()); err != ErrLeader {
+		t.Errorf("second Campaign = %v, want ErrLeader", err)
+	}
+}
+
+func TestEndedContextIsNotElected(t *testing.T) {
+	m := lease.NewMemory()
+	stop := errors.New("stop")
+	ctx, cancel := context.WithCancelCause(context.Background())
+	cancel(stop)
+
+	e := New(m, "leader", time.Second)
+	if lctx, err := e.Campaign(ctx); err != stop || lctx != nil {
+		t.Fatalf("Campaign = %v, %v, want the cause of ctx", lctx, err)
+	}
+	if e.Leading() {
+		t.Error("leading after a Campaign with an ended context")
+	}
+	if _, l, err := lease.Acquire(context.Background(), m, "leader", time.Second); err != nil {
+		t.Errorf("lease not free: %v", err)
+	} else {
+		l.Release()
+	}
+}
+
+func TestResignHandsOver(t *testing.T) {
+	m := lease.NewMemory()
+	a := New(m, "leader", time.Second, ID("a"))
+	b := New(m, "leader", time.Second, ID("b"), RetryEvery(5*time.Millisecond))
+	actx, err := a.Campaign(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := campaign(context.Background(), b)
+	time.Sleep(20 * time.Millisecond)
+	if b.Leading() {
+		t.Fatal("b elected while a leads")
+	}
+	if err := a.Resign(); err != nil {
+		t.Fatalf("Resign = %v", err)
+	}
+	if err := waitCause(t, actx); err != ErrResigned {
+		t.Errorf("a's cause = %v, want ErrResigned", err)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("b's Campaign = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("b not elected after a resigned")
+	}
+	defer b.Resign()
+	if a.Leading() || !b.Leading() {
+		t.Errorf("Leading: a = %v, b = %v, want b only", a.Leading(), b.Leading())
+	}
+}
+
+func TestLostLeaseEndsLeadership(t *testing.T) {
+	m := lease.NewMemory()
+	e := New(m, "leader", 30*time.Millisecond)
+	ctx, err := e.Campaign(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Expire("leader")
+	if err := waitCause(t, ctx); !errors.Is(err, lease.ErrLost) {
+		t.Errorf("cause = %v, want a lease.LostError", err)
+	}
+	if e.Leading() {
+		t.Error("still leading after the lease was lost")
+	}
+
+	// The candidate can campaign again once its term is over.
+	ctx2, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := e.Campaign(ctx2); err != nil {
+		t.Fatalf("Campaign after losing the lease = %v", err)
+	}
+	e.Resign()
+}
+
+func TestContextEndsWhileWaiting(t *testing.T) {
+	m := lease.NewMemory()
+	a := New(m, "leader", time.Second)
+	if _, err := a.Campaign(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer a.Resign()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	b := New(m, "leader", time.Second, RetryEvery(5*time.Millisecond))
+	select {
+	case err := <-campaign(ctx, b):
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Campaign = %v, want the deadline", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Campaign did not return when ctx ended")
+	}
+}
+
+func TestInvalidDurations(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for _, ttl := range []time.Duration{0, -time.Second} {
+		e := New(lease.NewMemory(), "leader", ttl)
+		if _, err := e.Campaign(ctx); !errors.Is(err, lease.ErrInvalidDuration) {
+			t.Errorf("Campaign with ttl %v = %v, want lease.ErrInvalidDuration", ttl, err)
+		}
+	}
+
+	// A tiny ttl or a non-positive RetryEvery falls back to a usable retry
+	// interval rather than panicking.
+	for _, e := range []*Election{
+		New(lease.NewMemory(), "leader", time.Nanosecond),
+		New(lease.NewMemory(), "leader", time.Second, RetryEvery(0)),
+		New(lease.NewMemory(), "leader", time.Second, RetryEvery(-time.Second)),
+	} {
+		if _, err := e.Campaign(ctx); err != nil {
+			t.Errorf("Campaign = %v", err)
+		}
+		e.Resign()
+	}
+}
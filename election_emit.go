@@ -0,0 +1,64 @@
+//go:build ignore
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/surenraju-zz/go-context/election"
+	"github.com/surenraju-zz/go-context/lease"
+)
+
+func lead(ctx context.Context, name string) {
+	// Like operation2 in emit_cancel.go, the leader works until its context says otherwise
+	for {
+		select {
+		case <-time.After(100 * time.Millisecond):
+			fmt.Println(name, "is doing leader work")
+		case <-ctx.Done():
+			fmt.Println(name, "stopped leading:", context.Cause(ctx))
+			return
+		}
+	}
+}
+
+func main() {
+	// Candidates in separate processes would share the lock directory the same way
+	holder, err := lease.NewFile(filepath.Join(os.TempDir(), "go-context-election"))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for _, name := range []string{"node-a", "node-b"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			e := election.New(holder, "scheduler", time.Second, election.ID(name))
+			// Campaign blocks while the other node leads
+			leaderCtx, err := e.Campaign(ctx)
+			if err != nil {
+				fmt.Println(name, "gave up campaigning:", err)
+				return
+			}
+			fmt.Println(name, "was elected")
+			// Step down after a while, like operation1 failing in emit_cancel.go
+			time.AfterFunc(500*time.Millisecond, func() { e.Resign() })
+			lead(leaderCtx, name)
+			if errors.Is(context.Cause(leaderCtx), election.ErrResigned) {
+				fmt.Println(name, "handed over leadership")
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,48 @@
+//go:build ignore
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/surenraju-zz/go-context/safego"
+)
+
+func operation1(ctx context.Context) error {
+	// This time the operation does not return an error, it panics
+	// Without recovery this would crash the whole process
+	time.Sleep(100 * time.Millisecond)
+	panic("something went badly wrong")
+}
+
+func operation2(ctx context.Context) error {
+	select {
+	case <-time.After(500 * time.Millisecond):
+		fmt.Println("operation2 competed")
+	case <-ctx.Done():
+		// The cause tells us why we were cancelled: the recovered panic
+		var pe *safego.PanicError
+		if errors.As(context.Cause(ctx), &pe) {
+			fmt.Println("operation2 canceled because of:", pe.Value)
+		}
+	}
+	return nil
+}
+
+func main() {
+	// Create a group, and a context that is cancelled
+	// as soon as any goroutine in the group fails or panics
+	g, ctx := safego.WithContext(context.Background())
+
+	// Run both operations in the group
+	safego.Go(ctx, operation1)
+	safego.Go(ctx, operation2)
+
+	// Wait returns the panic as an error, with its stack trace
+	if err := g.Wait(); err != nil {
+		fmt.Println("group failed:", err)
+	}
+}
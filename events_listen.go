@@ -0,0 +1,37 @@
+//go:build ignore
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/surenraju-zz/go-context/pubsub"
+)
+
+func main() {
+	// Subscribers that cannot keep up lose events, and are dropped after 5 seconds of it
+	bus := pubsub.New[string](pubsub.WithPolicy(pubsub.Drop), pubsub.DisconnectSlow(5*time.Second))
+
+	// Publish the time every second to anyone listening
+	go func() {
+		for t := range time.Tick(time.Second) {
+			bus.Publish(context.Background(), "clock", t.Format(time.TimeOnly))
+		}
+	}()
+
+	// Stream the events to each client with `curl localhost:8000`
+	http.ListenAndServe(":8000", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The subscription ends with the request, so a client hanging up cannot leak it
+		sub := bus.Subscribe(r.Context(), "clock")
+		w.Header().Set("Content-Type", "text/event-stream")
+		for event := range sub.C {
+			fmt.Fprintf(w, "data: %s\n\n", event)
+			w.(http.Flusher).Flush()
+		}
+		fmt.Fprintln(os.Stderr, "subscription ended:", sub.Err())
+	}))
+}
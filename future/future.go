@@ -0,0 +1,170 @@
+// Package future represents the result of an asynchronous, context-aware
+// computation as a value.
+//
+// A Future can be passed around and awaited by any number of goroutines,
+// each bounding its own wait with a context. Cancelling a Future cancels
+// the context of its computation and of every Future chained to it with
+// Then.
+package future
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrPending is returned by Result for a Future that does not hold a result
+// yet.
+var ErrPending = errors.New("future: result not ready")
+
+// Future is the eventual result of a function run by Go.
+type Future[T any] struct {
+	// parent is kept so that Then can derive its computation's context
+	// from the same one; it is never handed out.
+	parent context.Context
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+
+	once sync.Once
+	done chan struct{}
+	val  T
+	err  error
+
+	mu        sync.Mutex
+	cancelled error
+	children  []func(error)
+}
+
+func newFuture[T any](parent context.Context) *Future[T] {
+	f := &Future[T]{parent: parent, done: make(chan struct{})}
+	f.ctx, f.cancel = context.WithCancelCause(parent)
+	return f
+}
+
+// settle records the result if none was recorded yet.
+func (f *Future[T]) settle(v T, err error) {
+	f.once.Do(func() {
+		f.val, f.err = v, err
+		close(f.done)
+		f.cancel(nil)
+	})
+}
+
+// Go runs fn in a new goroutine under a context derived from ctx and
+// returns a Future for its result. A panic in fn is not recovered.
+func Go[T any](ctx context.Context, fn func(ctx context.Context) (T, error)) *Future[T] {
+	f := newFuture[T](ctx)
+	go func() {
+		v, err := fn(f.ctx)
+		f.settle(v, err)
+	}()
+	return f
+}
+
+// Resolved returns a Future that already holds v.
+func Resolved[T any](v T) *Future[T] {
+	f := newFuture[T](context.Background())
+	f.settle(v, nil)
+	return f
+}
+
+// Rejected returns a Future that already holds err.
+func Rejected[T any](err error) *Future[T] {
+	f := newFuture[T](context.Background())
+	var zero T
+	f.settle(zero, err)
+	return f
+}
+
+// Done returns a channel that is closed once the Future holds a result.
+func (f *Future[T]) Done() <-chan struct{} {
+	return f.done
+}
+
+// Await waits for the result. If ctx ends first, Await returns the cause of
+// ctx, and the computation keeps running for other waiters.
+func (f *Future[T]) Await(ctx context.Context) (T, error) {
+	select {
+	case <-f.done:
+		return f.val, f.err
+	case <-ctx.Done():
+		var zero T
+		return zero, context.Cause(ctx)
+	}
+}
+
+// Result returns the result without waiting. Before Done is closed, it
+// returns ErrPending.
+func (f *Future[T]) Result() (T, error) {
+	select {
+	case <-f.done:
+		return f.val, f.err
+	default:
+		var zero T
+		return zero, ErrPending
+	}
+}
+
+// Cancel cancels the computation's context with cause and settles the
+// Future with cause as its error, unless it already holds a result. A nil
+// cause means context.Canceled. Futures chained with Then are cancelled
+// too, even if f already held a result.
+func (f *Future[T]) Cancel(cause error) {
+	if cause == nil {
+		cause = context.Canceled
+	}
+	f.cancel(cause)
+	var zero T
+	f.settle(zero, cause)
+
+	f.mu.Lock()
+	if f.cancelled != nil {
+		f.mu.Unlock()
+		return
+	}
+	f.cancelled = cause
+	children := f.children
+	f.children = nil
+	f.mu.Unlock()
+	for _, cancel := range children {
+		cancel(cause)
+	}
+}
+
+// onCancel arranges for cancel to be called when f is cancelled.
+func (f *Future[T]) onCancel(cancel func(error)) {
+	f.mu.Lock()
+	if cause := f.cancelled; cause != nil {
+		f.mu.Unlock()
+		cancel(cause)
+		return
+	}
+	f.children = append(f.children, cancel)
+	f.mu.Unlock()
+}
+
+// Then returns a Future for fn applied to f's value. fn runs under a
+// context derived from the one f was started with, and cancelling f also
+// cancels the returned Future. If f fails or is cancelled, the returned
+// Future holds the same error and fn is not called. Cancelling the returned
+// Future leaves f alone.
+func Then[T, U any](f *Future[T], fn func(ctx context.Context, v T) (U, error)) *Future[U] {
+	g := newFuture[U](f.parent)
+	f.onCancel(g.Cancel)
+	go func() {
+		var zero U
+		select {
+		case <-f.done:
+		case <-g.ctx.Done():
+			g.settle(zero, context.Cause(g.ctx))
+			return
+		}
+		if f.err != nil {
+			g.settle(zero, f.err)
+			return
+		}
+		v, err := fn(g.ctx, f.val)
+		g.settle(v, err)
+	}()
+	return g
+}
@@ -0,0 +1,176 @@
+package future
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// blocked returns a function that waits for release or its context,
+// sending the cause on causes when cancelled.
+func blocked(release <-chan struct{}, causes chan<- error) func(context.Context) (int, error) {
+	return func(ctx context.Context) (int, error) {
+		select {
+		case <-release:
+			return 42, nil
+		case <-ctx.Done():
+			causes <- context.Cause(ctx)
+			return 0, context.Cause(ctx)
+		}
+	}
+}
+
+func await[T any](t *testing.T, f *Future[T]) (T, error) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	v, err := f.Await(ctx)
+	if errors.Is(err, context.DeadlineExceeded) {
+		t.Fatal("Future never settled")
+	}
+	return v, err
+}
+
+func TestAwait(t *testing.T) {
+	f := Go(context.Background(), func(context.Context) (int, error) { return 42, nil })
+	if v, err := await(t, f); v != 42 || err != nil {
+		t.Errorf("Await = %d, %v, want 42", v, err)
+	}
+	// Every waiter sees the same result.
+	if v, err := await(t, f); v != 42 || err != nil {
+		t.Errorf("second Await = %d, %v, want 42", v, err)
+	}
+}
+
+func TestAwaitContextEndsFirst(t *testing.T) {
+	release := make(chan struct{})
+	f := Go(context.Background(), blocked(release, nil))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := f.Await(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Await = %v, want the waiter's deadline", err)
+	}
+
+	close(release)
+	if v, err := await(t, f); v != 42 || err != nil {
+		t.Errorf("Await after release = %d, %v; the computation should keep running", v, err)
+	}
+}
+
+func TestResult(t *testing.T) {
+	release := make(chan struct{})
+	f := Go(context.Background(), blocked(release, nil))
+	if _, err := f.Result(); err != ErrPending {
+		t.Errorf("Result before settling = %v, want ErrPending", err)
+	}
+	close(release)
+	<-f.Done()
+	if v, err := f.Result(); v != 42 || err != nil {
+		t.Errorf("Result = %d, %v, want 42", v, err)
+	}
+}
+
+func TestResolvedAndRejected(t *testing.T) {
+	if v, err := Resolved("x").Result(); v != "x" || err != nil {
+		t.Errorf("Resolved = %q, %v", v, err)
+	}
+	boom := errors.New("boom")
+	if _, err := Rejected[int](boom).Result(); err != boom {
+		t.Errorf("Rejected = %v, want %v", err, boom)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	causes := make(chan error, 1)
+	f := Go(context.Background(), blocked(nil, causes))
+	stop := errors.New("stop")
+	f.Cancel(stop)
+
+	if _, err := await(t, f); err != stop {
+		t.Errorf("Await = %v, want the cancellation cause", err)
+	}
+	if err := <-causes; err != stop {
+		t.Errorf("computation cause = %v, want %v", err, stop)
+	}
+}
+
+func TestCancelAfterSettleKeepsResult(t *testing.T) {
+	f := Resolved(1)
+	f.Cancel(nil)
+	if v, err := f.Result(); v != 1 || err != nil {
+		t.Errorf("Result = %d, %v, want the earlier result", v, err)
+	}
+}
+
+func TestThen(t *testing.T) {
+	f := Go(context.Background(), func(context.Context) (int, error) { return 42, nil })
+	g := Then(f, func(ctx context.Context, v int) (string, error) { return strconv.Itoa(v), nil })
+	if v, err := await(t, g); v != "42" || err != nil {
+		t.Errorf("Then = %q, %v, want 42", v, err)
+	}
+}
+
+func TestThenPropagatesError(t *testing.T) {
+	boom := errors.New("boom")
+	called := false
+	g := Then(Rejected[int](boom), func(ctx context.Context, v int) (int, error) {
+		called = true
+		return v, nil
+	})
+	if _, err := await(t, g); err != boom {
+		t.Errorf("Then = %v, want %v", err, boom)
+	}
+	if called {
+		t.Error("fn called after a failure")
+	}
+}
+
+func TestCancelReachesChainedFutures(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	f := Go(context.Background(), blocked(release, make(chan error, 1)))
+	causes := make(chan error, 1)
+	g := Then(f, func(ctx context.Context, v int) (int, error) {
+		<-ctx.Done()
+		causes <- context.Cause(ctx)
+		return 0, context.Cause(ctx)
+	})
+	h := Then(g, func(ctx context.Context, v int) (int, error) { return v, nil })
+
+	stop := errors.New("stop")
+	f.Cancel(stop)
+	for _, fut := range []*Future[int]{g, h} {
+		if _, err := await(t, fut); err != stop {
+			t.Errorf("chained Await = %v, want %v", err, stop)
+		}
+	}
+}
+
+func TestCancellingChainedFutureLeavesParent(t *testing.T) {
+	release := make(chan struct{})
+	f := Go(context.Background(), blocked(release, nil))
+	g := Then(f, func(ctx context.Context, v int) (int, error) { return v, nil })
+	g.Cancel(nil)
+	if _, err := await(t, g); !errors.Is(err, context.Canceled) {
+		t.Errorf("Await = %v, want context.Canceled", err)
+	}
+
+	close(release)
+	if v, err := await(t, f); v != 42 || err != nil {
+		t.Errorf("parent = %d, %v, want it unaffected", v, err)
+	}
+}
+
+func TestParentContextCancelsComputation(t *testing.T) {
+	causes := make(chan error, 1)
+	ctx, cancel := context.WithCancelCause(context.Background())
+	f := Go(ctx, blocked(nil, causes))
+	stop := errors.New("stop")
+	cancel(stop)
+	if _, err := await(t, f); err != stop {
+		t.Errorf("Await = %v, want %v", err, stop)
+	}
+}
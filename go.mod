@@ -0,0 +1,3 @@
+module github.com/surenraju-zz/go-context
+
+go 1.23
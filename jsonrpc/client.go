@@ -0,0 +1,228 @@
+package jsonrpc
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrClosed is returned for calls pending on a stream whose reader ended.
+var ErrClosed = errors.New("jsonrpc: connection closed")
+
+// cancelTimeout bounds the cancellation notification sent after a caller's
+// context has already ended.
+const cancelTimeout = time.Second
+
+type transport interface {
+	call(ctx context.Context, req *Request) (*Response, error)
+	notify(ctx context.Context, req *Request) error
+}
+
+// Client calls methods on a Server.
+type Client struct {
+	t      transport
+	nextID atomic.Int64
+}
+
+// Call invokes method with params and decodes the result into result, which
+// may be nil. The remaining time until ctx's deadline is sent with the
+// call; if ctx ends before the response arrives, Call sends a cancellation
+// notification and returns the cause of ctx. Errors returned by the method
+// are of type *Error.
+func (c *Client) Call(ctx context.Context, method string, params, result any) error {
+	raw, err := marshalParams(params)
+	if err != nil {
+		return err
+	}
+	id := json.RawMessage(strconv.FormatInt(c.nextID.Add(1), 10))
+	req := &Request{JSONRPC: Version, ID: id, Method: method, Params: raw, TimeoutMs: timeoutMs(ctx)}
+
+	resp, err := c.t.call(ctx, req)
+	if ctx.Err() != nil {
+		nctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), cancelTimeout)
+		defer cancel()
+		p, _ := json.Marshal(cancelParams{ID: id})
+		c.t.notify(nctx, &Request{JSONRPC: Version, Method: CancelMethod, Params: p})
+		return context.Cause(ctx)
+	}
+	if err != nil {
+		return err
+	}
+	if resp.Error != nil {
+		return resp.Error
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(resp.Result, result)
+}
+
+// Notify invokes method without waiting for a result.
+func (c *Client) Notify(ctx context.Context, method string, params any) error {
+	raw, err := marshalParams(params)
+	if err != nil {
+		return err
+	}
+	return c.t.notify(ctx, &Request{JSONRPC: Version, Method: method, Params: raw, TimeoutMs: timeoutMs(ctx)})
+}
+
+func marshalParams(params any) (json.RawMessage, error) {
+	if params == nil {
+		return nil, nil
+	}
+	return json.Marshal(params)
+}
+
+// NewHTTPClient returns a Client that POSTs each request to url. A nil hc
+// means http.DefaultClient.
+func NewHTTPClient(url string, hc *http.Client) *Client {
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	return &Client{t: &httpTransport{url: url, hc: hc}}
+}
+
+type httpTransport struct {
+	url string
+	hc  *http.Client
+}
+
+func (t *httpTransport) post(ctx context.Context, req *Request) (*http.Response, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	hreq, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	hreq.Header.Set("Content-Type", "application/json")
+	return t.hc.Do(hreq)
+}
+
+func (t *httpTransport) call(ctx context.Context, req *Request) (*Response, error) {
+	hresp, err := t.post(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	defer hresp.Body.Close()
+	if hresp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("jsonrpc: unexpected HTTP status %s", hresp.Status)
+	}
+	var resp Response
+	if err := json.NewDecoder(hresp.Body).Decode(&resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+func (t *httpTransport) notify(ctx context.Context, req *Request) error {
+	if req.Method == CancelMethod {
+		// Aborting the POST already cancelled the call on the server.
+		return nil
+	}
+	hresp, err := t.post(ctx, req)
+	if err != nil {
+		return err
+	}
+	hresp.Body.Close()
+	return nil
+}
+
+// NewStreamClient returns a Client that writes newline-delimited requests
+// to w and reads responses from r, such as a subprocess's stdin and stdout.
+// Calls may be made concurrently.
+func NewStreamClient(r io.Reader, w io.Writer) *Client {
+	t := &streamTransport{w: w, pending: make(map[string]chan *Response)}
+	go t.read(r)
+	return &Client{t: t}
+}
+
+type streamTransport struct {
+	wmu sync.Mutex
+	w   io.Writer
+
+	mu      sync.Mutex
+	pending map[string]chan *Response
+	err     error
+}
+
+func (t *streamTransport) write(req *Request) error {
+	line, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	t.wmu.Lock()
+	defer t.wmu.Unlock()
+	_, err = t.w.Write(append(line, '\n'))
+	return err
+}
+
+func (t *streamTransport) call(ctx context.Context, req *Request) (*Response, error) {
+	key := string(req.ID)
+	ch := make(chan *Response, 1)
+	t.mu.Lock()
+	if t.err != nil {
+		t.mu.Unlock()
+		return nil, t.err
+	}
+	t.pending[key] = ch
+	t.mu.Unlock()
+	defer func() {
+		t.mu.Lock()
+		delete(t.pending, key)
+		t.mu.Unlock()
+	}()
+
+	if err := t.write(req); err != nil {
+		return nil, err
+	}
+	select {
+	case resp, ok := <-ch:
+		if !ok {
+			return nil, ErrClosed
+		}
+		return resp, nil
+	case <-ctx.Done():
+		return nil, context.Cause(ctx)
+	}
+}
+
+func (t *streamTransport) notify(ctx context.Context, req *Request) error {
+	return t.write(req)
+}
+
+func (t *streamTransport) read(r io.Reader) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 1<<20)
+	for sc.Scan() {
+		var resp Response
+		if json.Unmarshal(sc.Bytes(), &resp) != nil {
+			continue
+		}
+		t.mu.Lock()
+		ch := t.pending[string(resp.ID)]
+		delete(t.pending, string(resp.ID))
+		t.mu.Unlock()
+		if ch != nil {
+			ch <- &resp
+		}
+	}
+
+	t.mu.Lock()
+	t.err = ErrClosed
+	for key, ch := range t.pending {
+		delete(t.pending, key)
+		close(ch)
+	}
+	t.mu.Unlock()
+}
@@ -0,0 +1,122 @@
+// Package jsonrpc is a small JSON-RPC 2.0 implementation that propagates
+// deadlines and cancellation.
+//
+// Every call carries the client's remaining time budget in a "timeoutMs"
+// member, and the server runs the method under a context with that
+// deadline. A client whose context ends aborts the HTTP request, or on a
+// stream sends a "$/cancelRequest" notification naming the call, and the
+// server cancels the call's context.
+// Both HTTP and newline-delimited streams such as stdio are supported.
+// Batch requests are not.
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Version is the only protocol version supported.
+const Version = "2.0"
+
+// CancelMethod is the notification that cancels an in-flight call.
+const CancelMethod = "$/cancelRequest"
+
+// Error codes. The last two are server-defined codes for calls ended by
+// their context.
+const (
+	CodeParseError       = -32700
+	CodeInvalidRequest   = -32600
+	CodeMethodNotFound   = -32601
+	CodeInvalidParams    = -32602
+	CodeInternalError    = -32603
+	CodeRequestCancelled = -32800
+	CodeDeadlineExceeded = -32001
+)
+
+// Request is a call or, when ID is empty, a notification.
+type Request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	// TimeoutMs is the caller's remaining budget in milliseconds. It is an
+	// extension to JSON-RPC 2.0; zero means no deadline.
+	TimeoutMs int64 `json:"timeoutMs,omitempty"`
+}
+
+// IsNotification reports whether r expects no response.
+func (r *Request) IsNotification() bool {
+	return len(r.ID) == 0
+}
+
+// Response is the reply to a call.
+type Response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+// Error is a JSON-RPC error object. Handlers may return one to control the
+// code sent to the client.
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    any    `json:"data,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("jsonrpc: %s (%d)", e.Message, e.Code)
+}
+
+// NewError returns an *Error with the given code and message.
+func NewError(code int, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+// toError converts an error returned by a handler, or the cause of its
+// context, into an *Error.
+func toError(err error) *Error {
+	var e *Error
+	switch {
+	case errors.As(err, &e):
+		return e
+	case errors.Is(err, errCancelRequested):
+		// Error adds its own "jsonrpc:" prefix.
+		return NewError(CodeRequestCancelled, "cancelled by client")
+	case errors.Is(err, context.DeadlineExceeded):
+		return NewError(CodeDeadlineExceeded, err.Error())
+	case errors.Is(err, context.Canceled):
+		return NewError(CodeRequestCancelled, err.Error())
+	default:
+		return NewError(CodeInternalError, err.Error())
+	}
+}
+
+// timeoutMs returns the time left until ctx's deadline in milliseconds, at
+// least 1, or 0 if ctx has no deadline.
+func timeoutMs(ctx context.Context) int64 {
+	d, ok := ctx.Deadline()
+	if !ok {
+		return 0
+	}
+	return max(time.Until(d).Milliseconds(), 1)
+}
+
+// maxTimeoutMs is the largest budget, in milliseconds, that fits in a
+// time.Duration.
+const maxTimeoutMs = math.MaxInt64 / int64(time.Millisecond)
+
+// timeout converts a budget in milliseconds from a request to a duration,
+// clamping budgets too large for a time.Duration to the largest one.
+func timeout(ms int64) time.Duration {
+	return time.Duration(min(ms, maxTimeoutMs)) * time.Millisecond
+}
+
+type cancelParams struct {
+	ID json.RawMessage `json:"id"`
+}
@@ -0,0 +1,218 @@
+package jsonrpc
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+)
+
+var errCancelRequested = errors.New("jsonrpc: cancelled by client")
+
+// A HandlerFunc runs a method. Its result is encoded as JSON.
+type HandlerFunc func(ctx context.Context, params json.RawMessage) (any, error)
+
+// Server dispatches calls to registered methods.
+type Server struct {
+	mu      sync.Mutex
+	methods map[string]HandlerFunc
+}
+
+// NewServer returns a Server with no methods.
+func NewServer() *Server {
+	return &Server{methods: make(map[string]HandlerFunc)}
+}
+
+// Register makes h available as method.
+func (s *Server) Register(method string, h HandlerFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.methods[method] = h
+}
+
+// calls tracks the calls in flight on one stream. Request IDs are only
+// unique per client, so a cancellation must not reach other streams.
+type calls struct {
+	mu sync.Mutex
+	m  map[string]*call
+}
+
+type call struct {
+	cancel context.CancelCauseFunc
+}
+
+func (cs *calls) add(id string, c *call) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.m[id] = c
+}
+
+// remove forgets c, unless a later call with the same ID replaced it.
+func (cs *calls) remove(id string, c *call) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	if cs.m[id] == c {
+		delete(cs.m, id)
+	}
+}
+
+func (cs *calls) cancel(id string) {
+	cs.mu.Lock()
+	c := cs.m[id]
+	cs.mu.Unlock()
+	if c != nil {
+		c.cancel(errCancelRequested)
+	}
+}
+
+// handle runs req under a context derived from ctx and returns its
+// response, or nil for a notification. Calls are registered in inflight so
+// that cancellation notifications can reach them; a nil inflight ignores
+// such notifications.
+func (s *Server) handle(ctx context.Context, req *Request, inflight *calls) *Response {
+	if req.JSONRPC != Version || req.Method == "" {
+		return s.errorResponse(req, NewError(CodeInvalidRequest, "invalid request"))
+	}
+	if req.Method == CancelMethod {
+		var p cancelParams
+		if inflight != nil && json.Unmarshal(req.Params, &p) == nil {
+			inflight.cancel(string(p.ID))
+		}
+		return nil
+	}
+
+	s.mu.Lock()
+	h, ok := s.methods[req.Method]
+	s.mu.Unlock()
+	if !ok {
+		return s.errorResponse(req, NewError(CodeMethodNotFound, "method not found: "+req.Method))
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	if req.TimeoutMs > 0 {
+		var stop context.CancelFunc
+		ctx, stop = context.WithTimeout(ctx, timeout(req.TimeoutMs))
+		defer stop()
+	}
+	if inflight != nil && !req.IsNotification() {
+		key, c := string(req.ID), &call{cancel: cancel}
+		inflight.add(key, c)
+		defer inflight.remove(key, c)
+	}
+
+	result, err := h(ctx, req.Params)
+	if err == nil && ctx.Err() != nil {
+		// The handler ignored its context; the client has stopped waiting
+		// for the result either way.
+		err = context.Cause(ctx)
+	}
+	if req.IsNotification() {
+		return nil
+	}
+	if err != nil {
+		return s.errorResponse(req, toError(err))
+	}
+	raw, err := json.Marshal(result)
+	if err != nil {
+		return s.errorResponse(req, NewError(CodeInternalError, err.Error()))
+	}
+	return &Response{JSONRPC: Version, ID: req.ID, Result: raw}
+}
+
+func (s *Server) errorResponse(req *Request, e *Error) *Response {
+	if req.IsNotification() {
+		return nil
+	}
+	return &Response{JSONRPC: Version, ID: req.ID, Error: e}
+}
+
+// ServeHTTP handles one request per HTTP POST. A call is cancelled when its
+// HTTP request is, so cancellation notifications are ignored.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req Request
+	var resp *Response
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		resp = &Response{JSONRPC: Version, ID: json.RawMessage("null"), Error: NewError(CodeParseError, err.Error())}
+	} else {
+		resp = s.handle(r.Context(), &req, nil)
+	}
+	if resp == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// ServeConn reads newline-delimited requests from r and writes responses to
+// w, handling calls concurrently so that a later cancellation can reach an
+// earlier call. It returns when r is exhausted and every call has finished,
+// or when ctx ends, which cancels the calls in flight. Cancellation
+// notifications only reach calls made on the same stream.
+func (s *Server) ServeConn(ctx context.Context, r io.Reader, w io.Writer) error {
+	var (
+		wmu      sync.Mutex
+		wg       sync.WaitGroup
+		enc      = json.NewEncoder(w)
+		inflight = &calls{m: make(map[string]*call)}
+	)
+	write := func(resp *Response) {
+		if resp == nil {
+			return
+		}
+		wmu.Lock()
+		defer wmu.Unlock()
+		enc.Encode(resp)
+	}
+
+	lines := make(chan []byte)
+	errc := make(chan error, 1)
+	go func() {
+		defer close(lines)
+		sc := bufio.NewScanner(r)
+		sc.Buffer(nil, 1<<20)
+		for sc.Scan() {
+			select {
+			case lines <- append([]byte(nil), sc.Bytes()...):
+			case <-ctx.Done():
+				return
+			}
+		}
+		errc <- sc.Err()
+	}()
+
+	defer wg.Wait()
+	for {
+		select {
+		case <-ctx.Done():
+			return context.Cause(ctx)
+		case line, ok := <-lines:
+			if !ok {
+				return <-errc
+			}
+			var req Request
+			if err := json.Unmarshal(line, &req); err != nil {
+				write(&Response{JSONRPC: Version, ID: json.RawMessage("null"), Error: NewError(CodeParseError, err.Error())})
+				continue
+			}
+			if req.Method == CancelMethod {
+				s.handle(ctx, &req, inflight)
+				continue
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				write(s.handle(ctx, &req, inflight))
+			}()
+		}
+	}
+}
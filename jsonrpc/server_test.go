@@ -0,0 +1,216 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newServer returns a server with an "echo" method and a "wait" method
+// that blocks until its context ends or release is closed, reporting the
+// cause on causes.
+func newServer(causes chan<- error, release <-chan struct{}) *Server {
+	s := NewServer()
+	s.Register("echo", func(ctx context.Context, params json.RawMessage) (any, error) {
+		var v string
+		if err := json.Unmarshal(params, &v); err != nil {
+			return nil, NewError(CodeInvalidParams, err.Error())
+		}
+		return v, nil
+	})
+	s.Register("wait", func(ctx context.Context, params json.RawMessage) (any, error) {
+		select {
+		case <-ctx.Done():
+			causes <- context.Cause(ctx)
+			return nil, context.Cause(ctx)
+		case <-release:
+			causes <- nil
+			return "released", nil
+		}
+	})
+	return s
+}
+
+// streamClient serves s over a pair of pipes and returns a client for it.
+func streamClient(t *testing.T, s *Server) *Client {
+	t.Helper()
+	reqR, reqW := io.Pipe()
+	respR, respW := io.Pipe()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.ServeConn(ctx, reqR, respW)
+		respW.Close()
+	}()
+	t.Cleanup(func() {
+		cancel()
+		reqW.Close()
+		<-done
+	})
+	return NewStreamClient(respR, reqW)
+}
+
+func waitCause(t *testing.T, causes <-chan error) error {
+	t.Helper()
+	select {
+	case err := <-causes:
+		return err
+	case <-time.After(5 * time.Second):
+		t.Fatal("handler did not finish")
+		return nil
+	}
+}
+
+func TestHTTPCall(t *testing.T) {
+	srv := httptest.NewServer(newServer(nil, nil))
+	defer srv.Close()
+	c := NewHTTPClient(srv.URL, nil)
+
+	var got string
+	if err := c.Call(context.Background(), "echo", "hi", &got); err != nil || got != "hi" {
+		t.Errorf("Call = %q, %v, want hi", got, err)
+	}
+
+	err := c.Call(context.Background(), "missing", nil, nil)
+	var e *Error
+	if !errors.As(err, &e) || e.Code != CodeMethodNotFound {
+		t.Errorf("Call = %v, want method not found", err)
+	}
+}
+
+func TestHTTPCancel(t *testing.T) {
+	causes := make(chan error, 1)
+	srv := httptest.NewServer(newServer(causes, nil))
+	defer srv.Close()
+	c := NewHTTPClient(srv.URL, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	if err := c.Call(ctx, "wait", nil, nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("Call = %v, want context.Canceled", err)
+	}
+	if err := waitCause(t, causes); err == nil {
+		t.Error("handler was not cancelled")
+	}
+}
+
+func TestDeadlineIsPropagated(t *testing.T) {
+	deadlines := make(chan time.Duration, 1)
+	s := NewServer()
+	s.Register("deadline", func(ctx context.Context, params json.RawMessage) (any, error) {
+		d, _ := ctx.Deadline()
+		deadlines <- time.Until(d)
+		return nil, nil
+	})
+	c := streamClient(t, s)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := c.Call(ctx, "deadline", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if d := <-deadlines; d <= 0 || d > time.Second {
+		t.Errorf("server budget = %v, want just under a second", d)
+	}
+}
+
+func TestHugeTimeoutIsClamped(t *testing.T) {
+	type result struct {
+		err  error
+		left time.Duration
+	}
+	results := make(chan result, 1)
+	s := NewServer()
+	s.Register("deadline", func(ctx context.Context, params json.RawMessage) (any, error) {
+		d, _ := ctx.Deadline()
+		results <- result{ctx.Err(), time.Until(d)}
+		return nil, nil
+	})
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	// 1e16ms overflows a time.Duration, and 9223372036854775807ms wraps
+	// to a negative one.
+	for _, ms := range []string{"10000000000000000", "9223372036854775807"} {
+		body := `{"jsonrpc":"2.0","id":1,"method":"deadline","timeoutMs":` + ms + `}`
+		resp, err := http.Post(srv.URL, "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		r := <-results
+		if r.err != nil || r.left < 100*365*24*time.Hour {
+			t.Errorf("timeoutMs %s: ctx.Err = %v, budget = %v, want a far deadline", ms, r.err, r.left)
+		}
+	}
+}
+
+func TestStreamCancel(t *testing.T) {
+	causes := make(chan error, 1)
+	c := streamClient(t, newServer(causes, nil))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	if err := c.Call(ctx, "wait", nil, nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("Call = %v, want context.Canceled", err)
+	}
+	if err := waitCause(t, causes); !errors.Is(err, errCancelRequested) {
+		t.Errorf("handler cause = %v, want a cancellation request", err)
+	}
+}
+
+func TestCancelIsScopedToStream(t *testing.T) {
+	causes := make(chan error, 2)
+	release := make(chan struct{})
+	s := newServer(causes, release)
+	a, b := streamClient(t, s), streamClient(t, s)
+
+	// Both clients number their first call 1; b's call starts last, so a
+	// server-wide table would route a's cancellation to it.
+	ctx, cancel := context.WithCancel(context.Background())
+	ares := make(chan error, 1)
+	go func() { ares <- a.Call(ctx, "wait", nil, nil) }()
+	time.Sleep(20 * time.Millisecond)
+	bres := make(chan error, 1)
+	go func() { bres <- b.Call(context.Background(), "wait", nil, nil) }()
+	time.Sleep(20 * time.Millisecond)
+
+	cancel()
+	<-ares
+	if err := waitCause(t, causes); !errors.Is(err, errCancelRequested) {
+		t.Fatalf("first handler cause = %v, want a cancellation request", err)
+	}
+
+	close(release)
+	if err := waitCause(t, causes); err != nil {
+		t.Errorf("b's call was cancelled by a: %v", err)
+	}
+	if err := <-bres; err != nil {
+		t.Errorf("b's Call = %v", err)
+	}
+}
+
+func TestCancelledErrorMessage(t *testing.T) {
+	want := "jsonrpc: cancelled by client (-32800)"
+	if got := toError(errCancelRequested).Error(); got != want {
+		t.Errorf("Error = %q, want %q", got, want)
+	}
+}
+
+func TestStreamClosed(t *testing.T) {
+	reqR, reqW := io.Pipe()
+	respR, respW := io.Pipe()
+	go io.Copy(io.Discard, reqR)
+	c := NewStreamClient(respR, reqW)
+	time.AfterFunc(20*time.Millisecond, func() { respW.Close() })
+	if err := c.Call(context.Background(), "echo", "hi", nil); !errors.Is(err, ErrClosed) {
+		t.Errorf("Call = %v, want ErrClosed", err)
+	}
+}
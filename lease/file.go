@@ -0,0 +1,105 @@
+package lease
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// File is a Holder backed by advisory lock files in a directory, one per
+// key. The operating system drops the lock when the holding process exits,
+// so the ttl only paces renewals: renewing checks that the lock file is
+// still the one that was locked. Only processes on the same machine, or
+// sharing a file system with working locks, are coordinated.
+//
+// File is only supported on systems with flock(2); elsewhere Acquire
+// returns errors.ErrUnsupported.
+type File struct {
+	dir string
+
+	mu   sync.Mutex
+	held map[string]fileLease
+}
+
+type fileLease struct {
+	owner string
+	f     *os.File
+}
+
+// NewFile returns a File that keeps its lock files in dir, creating dir if
+// needed.
+func NewFile(dir string) (*File, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &File{dir: dir, held: make(map[string]fileLease)}, nil
+}
+
+func (h *File) path(key string) string {
+	return filepath.Join(h.dir, key+".lock")
+}
+
+// Acquire implements Holder. The owner is written to the lock file for
+// the benefit of anyone looking at it.
+func (h *File) Acquire(_ context.Context, key, owner string, _ time.Duration) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if cur, ok := h.held[key]; ok {
+		if cur.owner == owner {
+			return nil
+		}
+		return ErrHeld
+	}
+	f, err := os.OpenFile(h.path(key), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	if err := tryLock(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Truncate(0); err == nil {
+		fmt.Fprintln(f, owner)
+	}
+	h.held[key] = fileLease{owner: owner, f: f}
+	return nil
+}
+
+// Renew implements Holder. The lease is lost if the lock file has been
+// removed or replaced since it was locked.
+func (h *File) Renew(_ context.Context, key, owner string, _ time.Duration) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	cur, ok := h.held[key]
+	if !ok || cur.owner != owner {
+		return ErrNotHeld
+	}
+	locked, err := cur.f.Stat()
+	if err != nil {
+		return err
+	}
+	onDisk, err := os.Stat(h.path(key))
+	if errors.Is(err, os.ErrNotExist) || err == nil && !os.SameFile(locked, onDisk) {
+		cur.f.Close()
+		delete(h.held, key)
+		return ErrNotHeld
+	}
+	return err
+}
+
+// Release implements Holder. The lock file is left in place, so that it
+// cannot be removed while another process is about to lock it.
+func (h *File) Release(_ context.Context, key, owner string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	cur, ok := h.held[key]
+	if !ok || cur.owner != owner {
+		return ErrNotHeld
+	}
+	delete(h.held, key)
+	return cur.f.Close()
+}
@@ -0,0 +1,56 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package lease
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestFileExcludesOtherHolders(t *testing.T) {
+	dir := t.TempDir()
+	a, err := NewFile(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := NewFile(dir)
+
+	ctx := context.Background()
+	if err := a.Acquire(ctx, "job", "a", time.Second); err != nil {
+		t.Fatal(err)
+	}
+	// The lock is taken through a separate open, as another process would.
+	if err := b.Acquire(ctx, "job", "b", time.Second); !errors.Is(err, ErrHeld) {
+		t.Errorf("Acquire by another holder = %v, want ErrHeld", err)
+	}
+	if err := a.Renew(ctx, "job", "a", time.Second); err != nil {
+		t.Errorf("Renew = %v", err)
+	}
+	if err := a.Release(ctx, "job", "a"); err != nil {
+		t.Fatalf("Release = %v", err)
+	}
+	if err := b.Acquire(ctx, "job", "b", time.Second); err != nil {
+		t.Errorf("Acquire after Release = %v", err)
+	}
+}
+
+func TestFileRemovedLockIsLost(t *testing.T) {
+	dir := t.TempDir()
+	h, err := NewFile(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if err := h.Acquire(ctx, "job", "a", time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(h.path("job")); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Renew(ctx, "job", "a", time.Second); !errors.Is(err, ErrNotHeld) {
+		t.Errorf("Renew = %v, want ErrNotHeld", err)
+	}
+}
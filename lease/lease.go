@@ -0,0 +1,200 @@
+// Package lease keeps a context alive only while its holder keeps a lease.
+//
+// Acquire takes a named lease from a Holder and returns a context that
+// lives as long as the lease: a background heartbeat renews it, and once a
+// renewal is refused, or none has succeeded before the lease expires, the
+// context is cancelled with a *LostError. Work run under that context stops
+// before another owner can take over.
+//
+// Memory is a Holder for tests and for goroutines within one process; File
+// uses lock files so that only one process on a machine runs a job at a
+// time.
+package lease
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+var (
+	// ErrHeld is returned by Holder.Acquire when another owner holds the
+	// lease.
+	ErrHeld = errors.New("lease: held by another owner")
+	// ErrNotHeld is returned by Holder.Renew and Holder.Release when the
+	// owner does not hold the lease, for example because it expired.
+	ErrNotHeld = errors.New("lease: not held")
+	// ErrLost matches every *LostError with errors.Is.
+	ErrLost = errors.New("lease: lost")
+	// ErrInvalidDuration is returned by Acquire when the ttl or the
+	// renewal interval is not positive.
+	ErrInvalidDuration = errors.New("lease: ttl and renewal interval must be positive")
+)
+
+// LostError is the cancellation cause of a lease context whose lease could
+// not be kept.
+type LostError struct {
+	Key string
+	// Err is the error of the last failed renewal.
+	Err error
+}
+
+func (e *LostError) Error() string {
+	return fmt.Sprintf("lease: lost %q: %v", e.Key, e.Err)
+}
+
+func (e *LostError) Unwrap() []error { return []error{ErrLost, e.Err} }
+
+// Holder grants leases on named keys to owners. Implementations must be
+// safe for concurrent use.
+type Holder interface {
+	// Acquire grants the lease on key to owner for ttl, or returns ErrHeld
+	// if another owner holds it.
+	Acquire(ctx context.Context, key, owner string, ttl time.Duration) error
+	// Renew extends the lease owner holds on key by ttl from now, or
+	// returns ErrNotHeld if owner no longer holds it.
+	Renew(ctx context.Context, key, owner string, ttl time.Duration) error
+	// Release gives up the lease owner holds on key.
+	Release(ctx context.Context, key, owner string) error
+}
+
+type options struct {
+	owner string
+	every time.Duration
+}
+
+// Option configures Acquire.
+type Option func(*options)
+
+// Owner sets the identity under which the lease is held. The default is
+// unique to the call: the host name, the process ID and a random suffix.
+func Owner(id string) Option {
+	return func(o *options) { o.owner = id }
+}
+
+// RenewEvery sets how often the lease is renewed. The default is a third of
+// its ttl, which leaves room for two failed renewals before it expires.
+func RenewEvery(d time.Duration) Option {
+	return func(o *options) { o.every = d }
+}
+
+// Lease is a lease held by this process.
+type Lease struct {
+	holder Holder
+	key    string
+	owner  string
+	ttl    time.Duration
+	every  time.Duration
+
+	cancel context.CancelCauseFunc
+	stop   context.CancelFunc
+	done   chan struct{}
+	err    error
+}
+
+// Acquire takes the lease on key from h for ttl and returns a context
+// derived from ctx that is cancelled with a *LostError if the lease is lost.
+// If the lease is held by another owner, Acquire returns ErrHeld at once;
+// it does not wait for the lease to become free.
+//
+// The lease is renewed until Release is called or ctx ends, after which it
+// is released. Acquire returns ErrInvalidDuration if ttl or the interval
+// set by RenewEvery is not positive.
+func Acquire(ctx context.Context, h Holder, key string, ttl time.Duration, opts ...Option) (context.Context, *Lease, error) {
+	o := options{every: max(ttl/3, 1)}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if ttl <= 0 || o.every <= 0 {
+		return nil, nil, ErrInvalidDuration
+	}
+	if o.owner == "" {
+		o.owner = newOwner()
+	}
+	start := time.Now()
+	if err := h.Acquire(ctx, key, o.owner, ttl); err != nil {
+		return nil, nil, err
+	}
+	l := &Lease{holder: h, key: key, owner: o.owner, ttl: ttl, every: o.every, done: make(chan struct{})}
+	ctx, l.cancel = context.WithCancelCause(ctx)
+	var loop context.Context
+	loop, l.stop = context.WithCancel(ctx)
+	go l.heartbeat(loop, start.Add(ttl))
+	return ctx, l, nil
+}
+
+// Key returns the key of the lease.
+func (l *Lease) Key() string { return l.key }
+
+// Owner returns the identity under which the lease is held.
+func (l *Lease) Owner() string { return l.owner }
+
+// Release stops renewing the lease, gives it up and cancels its context
+// with context.Canceled. It returns the error from the Holder, or nil if
+// the lease had already been lost.
+func (l *Lease) Release() error {
+	l.stop()
+	<-l.done
+	l.cancel(context.Canceled)
+	return l.err
+}
+
+func (l *Lease) heartbeat(ctx context.Context, expires time.Time) {
+	defer close(l.done)
+	tick := time.NewTicker(l.every)
+	defer tick.Stop()
+	expiry := time.NewTimer(time.Until(expires))
+	defer expiry.Stop()
+
+	var last error
+	for {
+		select {
+		case <-ctx.Done():
+			l.release(ctx)
+			return
+		case <-expiry.C:
+			if last == nil {
+				last = context.DeadlineExceeded
+			}
+			l.cancel(&LostError{Key: l.key, Err: last})
+			return
+		case <-tick.C:
+		}
+
+		start := time.Now()
+		rctx, cancel := context.WithDeadline(ctx, expires)
+		err := l.holder.Renew(rctx, l.key, l.owner, l.ttl)
+		cancel()
+		switch {
+		case err == nil:
+			expires = start.Add(l.ttl)
+			expiry.Reset(time.Until(expires))
+			last = nil
+		case errors.Is(err, ErrNotHeld):
+			l.cancel(&LostError{Key: l.key, Err: err})
+			return
+		default:
+			// Possibly transient: keep trying until the lease expires.
+			last = err
+		}
+	}
+}
+
+// release gives the lease back once ctx has ended, still allowing the
+// Holder up to a ttl to respond.
+func (l *Lease) release(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), l.ttl)
+	defer cancel()
+	l.err = l.holder.Release(ctx, l.key, l.owner)
+}
+
+func newOwner() string {
+	host, _ := os.Hostname()
+	b := make([]byte, 4)
+	rand.Read(b)
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(b))
+}
@@ -0,0 +1,180 @@
+package lease
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func waitCause(t *testing.T, ctx context.Context) error {
+	t.Helper()
+	select {
+	case <-ctx.Done():
+		return context.Cause(ctx)
+	case <-time.After(5 * time.Second):
+		t.Fatal("lease context not cancelled")
+		return nil
+	}
+}
+
+func TestAcquireExcludesOthers(t *testing.T) {
+	m := NewMemory()
+	_, l, err := Acquire(context.Background(), m, "job", time.Second, Owner("a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Release()
+	if l.Key() != "job" || l.Owner() != "a" {
+		t.Errorf("lease = %s/%s, want job/a", l.Key(), l.Owner())
+	}
+	if _, _, err := Acquire(context.Background(), m, "job", time.Second, Owner("b")); !errors.Is(err, ErrHeld) {
+		t.Errorf("second Acquire = %v, want ErrHeld", err)
+	}
+}
+
+func TestRenewalOutlivesTTL(t *testing.T) {
+	m := NewMemory()
+	ctx, l, err := Acquire(context.Background(), m, "job", 30*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Release()
+	time.Sleep(100 * time.Millisecond)
+	if ctx.Err() != nil {
+		t.Fatalf("lease lost despite renewals: %v", context.Cause(ctx))
+	}
+	if _, _, err := Acquire(context.Background(), m, "job", time.Second); !errors.Is(err, ErrHeld) {
+		t.Errorf("Acquire by another owner = %v, want ErrHeld", err)
+	}
+}
+
+func TestLostLeaseCancels(t *testing.T) {
+	m := NewMemory()
+	ctx, l, err := Acquire(context.Background(), m, "job", 30*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Release()
+	m.Expire("job")
+
+	err = waitCause(t, ctx)
+	var le *LostError
+	if !errors.As(err, &le) || le.Key != "job" || !errors.Is(err, ErrLost) || !errors.Is(err, ErrNotHeld) {
+		t.Errorf("cause = %v, want a LostError wrapping ErrNotHeld", err)
+	}
+}
+
+func TestReleaseFreesLease(t *testing.T) {
+	m := NewMemory()
+	ctx, l, err := Acquire(context.Background(), m, "job", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Release(); err != nil {
+		t.Fatalf("Release = %v", err)
+	}
+	if err := context.Cause(ctx); err != context.Canceled {
+		t.Errorf("cause = %v, want context.Canceled", err)
+	}
+	_, l2, err := Acquire(context.Background(), m, "job", time.Second)
+	if err != nil {
+		t.Fatalf("Acquire after Release = %v", err)
+	}
+	l2.Release()
+}
+
+func TestParentCancellationReleases(t *testing.T) {
+	m := NewMemory()
+	parent, cancel := context.WithCancel(context.Background())
+	_, l, err := Acquire(parent, m, "job", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	<-l.done
+	if _, l2, err := Acquire(context.Background(), m, "job", time.Second); err != nil {
+		t.Errorf("Acquire after the parent ended = %v", err)
+	} else {
+		l2.Release()
+	}
+}
+
+// flaky grants leases but fails every renewal with a transient error.
+type flaky struct {
+	*Memory
+	renewals atomic.Int32
+}
+
+var errUnavailable = errors.New("store unavailable")
+
+func (f *flaky) Renew(context.Context, string, string, time.Duration) error {
+	f.renewals.Add(1)
+	return errUnavailable
+}
+
+func TestTransientRenewErrorsUntilExpiry(t *testing.T) {
+	h := &flaky{Memory: NewMemory()}
+	start := time.Now()
+	ctx, l, err := Acquire(context.Background(), h, "job", 60*time.Millisecond, RenewEvery(10*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Release()
+
+	err = waitCause(t, ctx)
+	if !errors.Is(err, ErrLost) || !errors.Is(err, errUnavailable) {
+		t.Errorf("cause = %v, want a LostError wrapping the last renewal error", err)
+	}
+	if d := time.Since(start); d < 50*time.Millisecond {
+		t.Errorf("lost after %v, want it kept until the ttl ran out", d)
+	}
+	if n := h.renewals.Load(); n < 2 {
+		t.Errorf("renewals = %d, want retries before giving up", n)
+	}
+}
+
+func TestDefaultOwnersDiffer(t *testing.T) {
+	if newOwner() == newOwner() {
+		t.Error("default owners collide")
+	}
+}
+
+func TestInvalidDurations(t *testing.T) {
+	tests := []struct {
+		name string
+		ttl  time.Duration
+		opts []Option
+	}{
+		{"zero ttl", 0, nil},
+		{"negative ttl", -time.Second, nil},
+		{"zero renewal", time.Second, []Option{RenewEvery(0)}},
+		{"negative renewal", time.Second, []Option{RenewEvery(-time.Second)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMemory()
+			if _, _, err := Acquire(context.Background(), m, "job", tt.ttl, tt.opts...); err != ErrInvalidDuration {
+				t.Fatalf("Acquire = %v, want ErrInvalidDuration", err)
+			}
+			// The lease was not taken.
+			_, l, err := Acquire(context.Background(), m, "job", time.Second)
+			if err != nil {
+				t.Fatalf("lease held after a rejected Acquire: %v", err)
+			}
+			l.Release()
+		})
+	}
+}
+
+func TestTinyTTL(t *testing.T) {
+	ctx, l, err := Acquire(context.Background(), NewMemory(), "job", 2*time.Nanosecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Release()
+	if ctx.Err() == nil {
+		t.Error("lease context alive after Release")
+	}
+}
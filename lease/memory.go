@@ -0,0 +1,69 @@
+package lease
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Memory is a Holder that keeps leases in memory. It only coordinates
+// goroutines within one process, which makes it suited to tests.
+type Memory struct {
+	mu     sync.Mutex
+	leases map[string]memoryLease
+}
+
+type memoryLease struct {
+	owner   string
+	expires time.Time
+}
+
+// NewMemory returns an empty Memory.
+func NewMemory() *Memory {
+	return &Memory{leases: make(map[string]memoryLease)}
+}
+
+// Acquire implements Holder. An expired lease is free to take.
+func (m *Memory) Acquire(_ context.Context, key, owner string, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	if cur, ok := m.leases[key]; ok && cur.owner != owner && now.Before(cur.expires) {
+		return ErrHeld
+	}
+	m.leases[key] = memoryLease{owner: owner, expires: now.Add(ttl)}
+	return nil
+}
+
+// Renew implements Holder.
+func (m *Memory) Renew(_ context.Context, key, owner string, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	cur, ok := m.leases[key]
+	if !ok || cur.owner != owner || !now.Before(cur.expires) {
+		return ErrNotHeld
+	}
+	m.leases[key] = memoryLease{owner: owner, expires: now.Add(ttl)}
+	return nil
+}
+
+// Release implements Holder.
+func (m *Memory) Release(_ context.Context, key, owner string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cur, ok := m.leases[key]
+	if !ok || cur.owner != owner {
+		return ErrNotHeld
+	}
+	delete(m.leases, key)
+	return nil
+}
+
+// Expire ends the lease on key at once, as if its owner had stopped
+// renewing it. It lets tests exercise losing a lease.
+func (m *Memory) Expire(key string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.leases, key)
+}
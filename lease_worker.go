@@ -0,0 +1,48 @@
+//go:build ignore
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/surenraju-zz/go-context/lease"
+)
+
+func main() {
+	// Every copy of this program shares the same lock directory
+	holder, err := lease.NewFile(filepath.Join(os.TempDir(), "go-context-leases"))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	// Only one process at a time gets the lease; run a second copy to see it refused
+	ctx, l, err := lease.Acquire(context.Background(), holder, "nightly-report", 3*time.Second)
+	if errors.Is(err, lease.ErrHeld) {
+		fmt.Println("another worker is running the job")
+		return
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	defer l.Release()
+	fmt.Println("lease acquired by", l.Owner())
+
+	// The job runs under the lease context, so it stops if the lease is lost,
+	// for example when the lock file is deleted
+	for i := 1; i <= 10; i++ {
+		select {
+		case <-time.After(time.Second):
+			fmt.Println("step", i, "done")
+		case <-ctx.Done():
+			fmt.Println("stopping:", context.Cause(ctx))
+			return
+		}
+	}
+}
@@ -0,0 +1,252 @@
+// Package lifecycle runs the components of a service under one root context.
+//
+// Components start in dependency order and stop in reverse order, either
+// when the process receives a signal, when the parent context ends, or when
+// any component fails.
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// DefaultStopTimeout is used for components that do not set StopTimeout.
+const DefaultStopTimeout = 10 * time.Second
+
+// ErrExited is reported when a component's Run returns nil before it was
+// asked to stop.
+var ErrExited = errors.New("lifecycle: component exited unexpectedly")
+
+// ErrShutdown is the cause of a component context cancelled because the
+// service is shutting down.
+var ErrShutdown = errors.New("lifecycle: shutting down")
+
+// Component is one long-running part of a service, such as an HTTP server,
+// a background worker or a ticker.
+type Component struct {
+	// Name identifies the component in DependsOn and in errors.
+	Name string
+	// DependsOn lists components that must be started before this one and
+	// stopped after it.
+	DependsOn []string
+	// Start, if set, prepares the component. It runs synchronously, and the
+	// next component does not start until it returns. If the service shuts
+	// down meanwhile, its context is cancelled with ErrShutdown.
+	Start func(ctx context.Context) error
+	// Run does the component's work. It must return once ctx is cancelled.
+	Run func(ctx context.Context) error
+	// StopTimeout bounds how long Run may take to return after ctx is
+	// cancelled. Zero means DefaultStopTimeout.
+	StopTimeout time.Duration
+}
+
+// ComponentError reports the component that caused shutdown.
+type ComponentError struct {
+	Name string
+	Err  error
+}
+
+func (e *ComponentError) Error() string {
+	return fmt.Sprintf("lifecycle: component %s: %v", e.Name, e.Err)
+}
+
+func (e *ComponentError) Unwrap() error { return e.Err }
+
+// SignalError reports the signal that caused shutdown.
+type SignalError struct {
+	Signal os.Signal
+}
+
+func (e *SignalError) Error() string {
+	return fmt.Sprintf("lifecycle: received %v", e.Signal)
+}
+
+// StopTimeoutError reports a component that did not stop in time.
+type StopTimeoutError struct {
+	Name    string
+	Timeout time.Duration
+}
+
+func (e *StopTimeoutError) Error() string {
+	return fmt.Sprintf("lifecycle: component %s did not stop within %v", e.Name, e.Timeout)
+}
+
+// Manager starts and stops a set of components.
+type Manager struct {
+	// Signals that trigger shutdown. Nil means SIGINT and SIGTERM; an
+	// empty, non-nil slice disables signal handling, leaving shutdown to
+	// the parent context and the components.
+	Signals []os.Signal
+
+	components []Component
+}
+
+// New returns a Manager for the given components.
+func New(components ...Component) *Manager {
+	return &Manager{components: components}
+}
+
+// Add registers another component. It must be called before Run.
+func (m *Manager) Add(c Component) {
+	m.components = append(m.components, c)
+}
+
+type running struct {
+	c      Component
+	cancel context.CancelCauseFunc
+	done   chan struct{}
+}
+
+// Run starts every component and blocks until the service shuts down. The
+// returned error always includes the cause of shutdown: a *SignalError, a
+// *ComponentError, or the cause of ctx. Components that failed to stop in
+// time are reported alongside it as *StopTimeoutError.
+func (m *Manager) Run(ctx context.Context) error {
+	order, err := m.order()
+	if err != nil {
+		return err
+	}
+
+	root, shutdown := context.WithCancelCause(ctx)
+	defer shutdown(nil)
+
+	signals := m.Signals
+	if signals == nil {
+		signals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+	}
+	// signal.Notify with no signals would relay every signal.
+	sigc := make(chan os.Signal, 1)
+	if len(signals) > 0 {
+		signal.Notify(sigc, signals...)
+		defer signal.Stop(sigc)
+	}
+	go func() {
+		select {
+		case sig := <-sigc:
+			shutdown(&SignalError{Signal: sig})
+		case <-root.Done():
+		}
+	}()
+
+	// Components get a context that keeps the values of ctx but is only
+	// cancelled by the manager, so they can be stopped one at a time.
+	base := context.WithoutCancel(ctx)
+
+	var started []*running
+	for _, c := range order {
+		if root.Err() != nil {
+			break
+		}
+		r := &running{c: c, done: make(chan struct{})}
+		var cctx context.Context
+		cctx, r.cancel = context.WithCancelCause(base)
+		started = append(started, r)
+
+		if c.Start != nil {
+			// A Start still running at shutdown is cancelled, so that a
+			// signal can interrupt a slow start.
+			unhook := context.AfterFunc(root, func() { r.cancel(ErrShutdown) })
+			err := c.Start(cctx)
+			unhook()
+			if err != nil || root.Err() != nil {
+				if err != nil {
+					shutdown(&ComponentError{Name: c.Name, Err: err})
+				}
+				close(r.done)
+				break
+			}
+		}
+		go func() {
+			defer close(r.done)
+			err := c.Run(cctx)
+			if cctx.Err() != nil {
+				return
+			}
+			if err == nil {
+				err = ErrExited
+			}
+			shutdown(&ComponentError{Name: c.Name, Err: err})
+		}()
+	}
+
+	<-root.Done()
+	errs := []error{context.Cause(root)}
+	for i := len(started) - 1; i >= 0; i-- {
+		if err := stop(started[i]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func stop(r *running) error {
+	r.cancel(ErrShutdown)
+	timeout := r.c.StopTimeout
+	if timeout <= 0 {
+		timeout = DefaultStopTimeout
+	}
+	t := time.NewTimer(timeout)
+	defer t.Stop()
+	select {
+	case <-r.done:
+		return nil
+	case <-t.C:
+		return &StopTimeoutError{Name: r.c.Name, Timeout: timeout}
+	}
+}
+
+// order sorts the components so that every component follows its
+// dependencies, keeping registration order otherwise.
+func (m *Manager) order() ([]Component, error) {
+	byName := make(map[string]Component, len(m.components))
+	for _, c := range m.components {
+		if c.Run == nil {
+			return nil, fmt.Errorf("lifecycle: component %q has no Run function", c.Name)
+		}
+		if _, dup := byName[c.Name]; dup {
+			return nil, fmt.Errorf("lifecycle: duplicate component %q", c.Name)
+		}
+		byName[c.Name] = c
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int, len(m.components))
+	order := make([]Component, 0, len(m.components))
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch state[name] {
+		case visiting:
+			return fmt.Errorf("lifecycle: dependency cycle through %q", name)
+		case visited:
+			return nil
+		}
+		state[name] = visiting
+		c := byName[name]
+		for _, dep := range c.DependsOn {
+			if _, ok := byName[dep]; !ok {
+				return fmt.Errorf("lifecycle: component %q depends on unknown component %q", name, dep)
+			}
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		state[name] = visited
+		order = append(order, c)
+		return nil
+	}
+	for _, c := range m.components {
+		if err := visit(c.Name); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
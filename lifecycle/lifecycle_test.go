@@ -0,0 +1,237 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"os"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recorder logs the order in which components start and stop.
+type recorder struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (r *recorder) add(e string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, e)
+}
+
+func (r *recorder) get() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.events)
+}
+
+// component returns a component that records its start and stop and runs
+// until its context is cancelled.
+func (r *recorder) component(name string, deps ...string) Component {
+	return Component{
+		Name:      name,
+		DependsOn: deps,
+		Start: func(context.Context) error {
+			r.add("start " + name)
+			return nil
+		},
+		Run: func(ctx context.Context) error {
+			<-ctx.Done()
+			r.add("stop " + name)
+			return nil
+		},
+	}
+}
+
+// slowStart returns a component whose Start closes started and then blocks
+// until its context ends, reporting the cause on cause.
+func slowStart(started chan<- struct{}, cause chan<- error) Component {
+	return Component{
+		Name: "db",
+		Start: func(ctx context.Context) error {
+			close(started)
+			<-ctx.Done()
+			cause <- context.Cause(ctx)
+			return ctx.Err()
+		},
+		Run: func(ctx context.Context) error { <-ctx.Done(); return nil },
+	}
+}
+
+// runAsync runs m in the background and returns its result channel.
+func runAsync(ctx context.Context, m *Manager) <-chan error {
+	errc := make(chan error, 1)
+	go func() { errc <- m.Run(ctx) }()
+	return errc
+}
+
+func wait(t *testing.T, errc <-chan error) error {
+	t.Helper()
+	select {
+	case err := <-errc:
+		return err
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return")
+		return nil
+	}
+}
+
+func TestStartAndStopOrder(t *testing.T) {
+	var r recorder
+	m := New(r.component("http", "db", "cache"), r.component("cache", "db"), r.component("db"))
+	m.Signals = []os.Signal{}
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	stop := errors.New("test over")
+	time.AfterFunc(50*time.Millisecond, func() { cancel(stop) })
+	err := m.Run(ctx)
+	if !errors.Is(err, stop) {
+		t.Fatalf("Run = %v, want the cause of ctx", err)
+	}
+
+	want := []string{"start db", "start cache", "start http", "stop http", "stop cache", "stop db"}
+	if got := r.get(); !slices.Equal(got, want) {
+		t.Errorf("events = %q, want %q", got, want)
+	}
+}
+
+func TestFailingComponentShutsDownOthers(t *testing.T) {
+	var r recorder
+	boom := errors.New("boom")
+	m := New(r.component("db"), Component{
+		Name:      "worker",
+		DependsOn: []string{"db"},
+		Run: func(context.Context) error {
+			return boom
+		},
+	})
+	m.Signals = []os.Signal{}
+
+	err := m.Run(context.Background())
+	var ce *ComponentError
+	if !errors.As(err, &ce) || ce.Name != "worker" || !errors.Is(err, boom) {
+		t.Fatalf("Run = %v, want a ComponentError for worker", err)
+	}
+	if got := r.get(); !slices.Contains(got, "stop db") {
+		t.Errorf("db was not stopped: %q", got)
+	}
+}
+
+func TestUnexpectedExit(t *testing.T) {
+	m := New(Component{Name: "oneshot", Run: func(context.Context) error { return nil }})
+	m.Signals = []os.Signal{}
+	if err := m.Run(context.Background()); !errors.Is(err, ErrExited) {
+		t.Errorf("Run = %v, want ErrExited", err)
+	}
+}
+
+func TestStartFailureSkipsLaterComponents(t *testing.T) {
+	var r recorder
+	bad := errors.New("cannot connect")
+	m := New(Component{
+		Name:  "db",
+		Start: func(context.Context) error { return bad },
+		Run:   func(ctx context.Context) error { <-ctx.Done(); return nil },
+	}, r.component("http", "db"))
+	m.Signals = []os.Signal{}
+
+	if err := m.Run(context.Background()); !errors.Is(err, bad) {
+		t.Fatalf("Run = %v, want the start error", err)
+	}
+	if got := r.get(); len(got) != 0 {
+		t.Errorf("http ran after db failed to start: %q", got)
+	}
+}
+
+func TestStopTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	m := New(Component{
+		Name:        "stuck",
+		StopTimeout: 20 * time.Millisecond,
+		Run: func(context.Context) error {
+			<-release
+			return nil
+		},
+	})
+	m.Signals = []os.Signal{}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := m.Run(ctx)
+	var st *StopTimeoutError
+	if !errors.As(err, &st) || st.Name != "stuck" {
+		t.Errorf("Run = %v, want a StopTimeoutError for stuck", err)
+	}
+}
+
+func TestComponentContextCause(t *testing.T) {
+	causes := make(chan error, 1)
+	m := New(Component{
+		Name: "worker",
+		Run: func(ctx context.Context) error {
+			<-ctx.Done()
+			causes <- context.Cause(ctx)
+			return nil
+		},
+	})
+	m.Signals = []os.Signal{}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	m.Run(ctx)
+	if err := <-causes; !errors.Is(err, ErrShutdown) {
+		t.Errorf("component cause = %v, want ErrShutdown", err)
+	}
+}
+
+func TestOrderErrors(t *testing.T) {
+	run := func(ctx context.Context) error { return nil }
+	tests := []struct {
+		name       string
+		components []Component
+	}{
+		{"cycle", []Component{{Name: "a", DependsOn: []string{"b"}, Run: run}, {Name: "b", DependsOn: []string{"a"}, Run: run}}},
+		{"unknown dependency", []Component{{Name: "a", DependsOn: []string{"missing"}, Run: run}}},
+		{"duplicate", []Component{{Name: "a", Run: run}, {Name: "a", Run: run}}},
+		{"no run", []Component{{Name: "a"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := New(tt.components...).Run(context.Background()); err == nil {
+				t.Error("Run = nil, want an error")
+			}
+		})
+	}
+}
+
+func TestParentCancelInterruptsStart(t *testing.T) {
+	var r recorder
+	started, cause := make(chan struct{}), make(chan error, 1)
+	m := New(slowStart(started, cause), r.component("http", "db"))
+	m.Signals = []os.Signal{}
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	stop := errors.New("stop")
+	errc := runAsync(ctx, m)
+	<-started
+	cancel(stop)
+
+	err := wait(t, errc)
+	if !errors.Is(err, stop) {
+		t.Errorf("Run = %v, want the cause of ctx", err)
+	}
+	var ce *ComponentError
+	if errors.As(err, &ce) {
+		t.Errorf("Run = %v, want the interrupted start not reported as a failure", err)
+	}
+	if err := <-cause; err != ErrShutdown {
+		t.Errorf("Start cause = %v, want ErrShutdown", err)
+	}
+	if got := r.get(); len(got) != 0 {
+		t.Errorf("http ran after db's start was interrupted: %q", got)
+	}
+}
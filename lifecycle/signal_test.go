@@ -0,0 +1,69 @@
+//go:build unix
+
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// TestSignalShutdown sends the signal only once a component has started,
+// when the Manager is known to have installed its handler; before that,
+// SIGUSR1 would kill the test binary.
+func TestSignalShutdown(t *testing.T) {
+	started := make(chan struct{})
+	m := New(Component{
+		Name:  "worker",
+		Start: func(context.Context) error { close(started); return nil },
+		Run:   func(ctx context.Context) error { <-ctx.Done(); return nil },
+	})
+	m.Signals = []os.Signal{syscall.SIGUSR1}
+
+	errc := runAsync(context.Background(), m)
+	<-started
+	syscall.Kill(os.Getpid(), syscall.SIGUSR1)
+
+	var se *SignalError
+	if err := wait(t, errc); !errors.As(err, &se) || se.Signal != syscall.SIGUSR1 {
+		t.Errorf("Run = %v, want a SignalError for SIGUSR1", err)
+	}
+}
+
+func TestSignalInterruptsStart(t *testing.T) {
+	started, cause := make(chan struct{}), make(chan error, 1)
+	m := New(slowStart(started, cause))
+	m.Signals = []os.Signal{syscall.SIGUSR1}
+
+	errc := runAsync(context.Background(), m)
+	<-started
+	syscall.Kill(os.Getpid(), syscall.SIGUSR1)
+
+	var se *SignalError
+	if err := wait(t, errc); !errors.As(err, &se) {
+		t.Errorf("Run = %v, want a SignalError", err)
+	}
+	if err := <-cause; err != ErrShutdown {
+		t.Errorf("Start cause = %v, want ErrShutdown", err)
+	}
+}
+
+func TestEmptySignalsDisablesHandling(t *testing.T) {
+	m := New(Component{Name: "worker", Run: func(ctx context.Context) error { <-ctx.Done(); return nil }})
+	m.Signals = []os.Signal{}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	errc := make(chan error, 1)
+	go func() { errc <- m.Run(ctx) }()
+	time.Sleep(10 * time.Millisecond)
+	// With every signal relayed, this would shut the service down.
+	syscall.Kill(os.Getpid(), syscall.SIGWINCH)
+
+	if err := <-errc; !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Run = %v, want the deadline of ctx", err)
+	}
+}
@@ -0,0 +1,61 @@
+// Package metadata carries arbitrary key/value metadata, such as the tenant,
+// locale or feature flags of a request, in the context.
+//
+// Metadata is attached with With and read with Get or FromContext. A Policy
+// decides which keys cross process boundaries as HTTP headers, bounds their
+// size, and redacts sensitive values when logging.
+package metadata
+
+import (
+	"context"
+	"maps"
+	"strings"
+)
+
+// MD is a set of metadata. Keys are lower case.
+type MD map[string]string
+
+type mdKey struct{}
+
+// With returns a copy of ctx whose metadata also maps key to value. The
+// metadata of ctx itself is not modified.
+func With(ctx context.Context, key, value string) context.Context {
+	md := maps.Clone(fromContext(ctx))
+	if md == nil {
+		md = make(MD)
+	}
+	md[strings.ToLower(key)] = value
+	return context.WithValue(ctx, mdKey{}, md)
+}
+
+// WithMD returns a copy of ctx carrying every pair in md in addition to the
+// metadata already in ctx. Pairs in md win.
+func WithMD(ctx context.Context, md MD) context.Context {
+	merged := maps.Clone(fromContext(ctx))
+	if merged == nil {
+		merged = make(MD, len(md))
+	}
+	for k, v := range md {
+		merged[strings.ToLower(k)] = v
+	}
+	return context.WithValue(ctx, mdKey{}, merged)
+}
+
+// Get returns the value of key in ctx's metadata, or "".
+func Get(ctx context.Context, key string) string {
+	return fromContext(ctx)[strings.ToLower(key)]
+}
+
+// FromContext returns a copy of ctx's metadata. It is never nil.
+func FromContext(ctx context.Context) MD {
+	md := maps.Clone(fromContext(ctx))
+	if md == nil {
+		md = make(MD)
+	}
+	return md
+}
+
+func fromContext(ctx context.Context) MD {
+	md, _ := ctx.Value(mdKey{}).(MD)
+	return md
+}
@@ -0,0 +1,188 @@
+package metadata
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWithDoesNotModifyParent(t *testing.T) {
+	parent := With(context.Background(), "Tenant", "acme")
+	child := With(parent, "locale", "fr")
+
+	if got := Get(parent, "locale"); got != "" {
+		t.Errorf("parent locale = %q, want it unset", got)
+	}
+	if got := Get(child, "TENANT"); got != "acme" {
+		t.Errorf("child tenant = %q, want acme", got)
+	}
+}
+
+func TestWithMDMerges(t *testing.T) {
+	ctx := With(context.Background(), "tenant", "acme")
+	ctx = WithMD(ctx, MD{"Tenant": "globex", "flags": "beta"})
+	want := MD{"tenant": "globex", "flags": "beta"}
+	if got := FromContext(ctx); len(got) != len(want) || got["tenant"] != "globex" || got["flags"] != "beta" {
+		t.Errorf("FromContext = %v, want %v", got, want)
+	}
+}
+
+func TestFromContextReturnsCopy(t *testing.T) {
+	if FromContext(context.Background()) == nil {
+		t.Fatal("FromContext returned nil")
+	}
+	ctx := With(context.Background(), "tenant", "acme")
+	FromContext(ctx)["tenant"] = "changed"
+	if got := Get(ctx, "tenant"); got != "acme" {
+		t.Errorf("tenant = %q after modifying a copy", got)
+	}
+}
+
+func TestInjectAppliesPolicy(t *testing.T) {
+	p := &Policy{Allow: []string{"tenant", "locale", "trace"}, MaxValueLen: 8}
+	ctx := WithMD(context.Background(), MD{
+		"tenant": "acme",
+		"locale": "fr",
+		"secret": "not allowed",
+		"trace":  strings.Repeat("x", 9),
+	})
+	h := http.Header{}
+	err := p.Inject(ctx, h)
+
+	if got := h.Get(HeaderPrefix + "tenant"); got != "acme" {
+		t.Errorf("tenant header = %q", got)
+	}
+	if got := h.Get(HeaderPrefix + "locale"); got != "fr" {
+		t.Errorf("locale header = %q", got)
+	}
+	if got := h.Get(HeaderPrefix + "secret"); got != "" {
+		t.Errorf("key outside Allow was propagated: %q", got)
+	}
+	var le *LimitError
+	if !errors.As(err, &le) || len(le.Keys) != 1 || le.Keys[0] != "trace" {
+		t.Errorf("Inject = %v, want a LimitError for trace", err)
+	}
+}
+
+func TestTotalLimit(t *testing.T) {
+	p := &Policy{Allow: []string{"a", "b"}, MaxTotalLen: 6}
+	ctx := WithMD(context.Background(), MD{"a": "1234", "b": "5678"})
+	h := http.Header{}
+	p.Inject(ctx, h)
+	if h.Get(HeaderPrefix+"a") == "" || h.Get(HeaderPrefix+"b") != "" {
+		t.Errorf("headers = %v, want only a within the total limit", h)
+	}
+}
+
+func TestZeroPolicyPropagatesNothing(t *testing.T) {
+	var p Policy
+	h := http.Header{}
+	if err := p.Inject(With(context.Background(), "tenant", "acme"), h); err != nil || len(h) != 0 {
+		t.Errorf("Inject = %v with headers %v, want nothing", err, h)
+	}
+}
+
+func TestExtractAppliesPolicy(t *testing.T) {
+	p := &Policy{Allow: []string{"tenant"}, MaxValueLen: 8}
+	h := http.Header{}
+	h.Set(HeaderPrefix+"tenant", "acme")
+	h.Set(HeaderPrefix+"admin", "true")
+	ctx := p.Extract(context.Background(), h)
+	if got := Get(ctx, "tenant"); got != "acme" {
+		t.Errorf("tenant = %q, want acme", got)
+	}
+	if got := Get(ctx, "admin"); got != "" {
+		t.Errorf("admin = %q, want headers outside Allow ignored", got)
+	}
+
+	h.Set(HeaderPrefix+"tenant", strings.Repeat("x", 9))
+	if got := Get(p.Extract(context.Background(), h), "tenant"); got != "" {
+		t.Errorf("oversized tenant = %q, want it dropped", got)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	p := &Policy{Allow: []string{"tenant"}}
+	got := make(chan string, 1)
+	srv := httptest.NewServer(p.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got <- Get(r.Context(), "tenant")
+	})))
+	defer srv.Close()
+
+	client := &http.Client{Transport: p.Transport(nil)}
+	ctx := With(context.Background(), "tenant", "acme")
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	// A stale header set by the caller is replaced by the context's value.
+	req.Header.Set(HeaderPrefix+"tenant", "stale")
+	res, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if v := <-got; v != "acme" {
+		t.Errorf("server saw tenant %q, want acme", v)
+	}
+	if v := req.Header.Get(HeaderPrefix + "tenant"); v != "stale" {
+		t.Errorf("Transport modified the caller's request: %q", v)
+	}
+}
+
+func TestInvalidHeadersAreDropped(t *testing.T) {
+	p := &Policy{Allow: []string{"tenant", "note", "bad key"}}
+	ctx := WithMD(context.Background(), MD{"tenant": "acme", "note": "a\r\nX-Admin: true", "bad key": "v"})
+	h := http.Header{}
+	err := p.Inject(ctx, h)
+	if len(h) != 1 || h.Get(HeaderPrefix+"tenant") != "acme" {
+		t.Errorf("headers = %v, want only tenant", h)
+	}
+	var le *LimitError
+	if !errors.As(err, &le) || strings.Join(le.Keys, ",") != "bad key,note" {
+		t.Errorf("Inject = %v, want a LimitError for the invalid keys", err)
+	}
+
+	in := http.Header{HeaderPrefix + "Tenant": {"acme"}, HeaderPrefix + "Note": {"a\x00b"}}
+	ctx = p.Extract(context.Background(), in)
+	if Get(ctx, "tenant") != "acme" || Get(ctx, "note") != "" {
+		t.Errorf("Extract = %v, want the invalid value dropped", FromContext(ctx))
+	}
+}
+
+func TestTransportSendsDespiteInvalidMetadata(t *testing.T) {
+	p := &Policy{Allow: []string{"tenant", "note"}}
+	got := make(chan string, 1)
+	srv := httptest.NewServer(p.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got <- Get(r.Context(), "tenant")
+	})))
+	defer srv.Close()
+
+	client := &http.Client{Transport: p.Transport(nil)}
+	ctx := WithMD(context.Background(), MD{"tenant": "acme", "note": "line1\nline2"})
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	res, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("request rejected: %v", err)
+	}
+	res.Body.Close()
+	if v := <-got; v != "acme" {
+		t.Errorf("server saw tenant %q, want acme", v)
+	}
+}
+
+func TestLogAttrRedacts(t *testing.T) {
+	p := &Policy{Sensitive: []string{"tenant"}}
+	ctx := WithMD(context.Background(), MD{"tenant": "acme", "locale": "fr"})
+	var buf bytes.Buffer
+	slog.New(slog.NewTextHandler(&buf, nil)).Info("msg", p.LogAttr(ctx))
+	out := buf.String()
+	if strings.Contains(out, "acme") || !strings.Contains(out, "metadata.tenant="+Redacted) {
+		t.Errorf("log = %s, want tenant redacted", out)
+	}
+	if !strings.Contains(out, "metadata.locale=fr") {
+		t.Errorf("log = %s, want locale logged", out)
+	}
+}
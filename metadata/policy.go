@@ -0,0 +1,220 @@
+package metadata
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// HeaderPrefix is prepended to a key to form its HTTP header name.
+const HeaderPrefix = "X-Md-"
+
+// Defaults used when the corresponding Policy field is zero.
+const (
+	DefaultMaxValueLen = 256
+	DefaultMaxTotalLen = 4096
+)
+
+// Redacted replaces sensitive values in logs.
+const Redacted = "[REDACTED]"
+
+// LimitError reports keys left out of headers because they exceeded a
+// Policy's size limits or could not be sent as a header.
+type LimitError struct {
+	Keys []string
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("metadata: keys over size limit or invalid in headers not propagated: %s", strings.Join(e.Keys, ", "))
+}
+
+// Policy controls how metadata crosses process boundaries and appears in
+// logs. The zero Policy propagates nothing.
+type Policy struct {
+	// Allow lists the keys forwarded as headers and accepted from them.
+	Allow []string
+	// Sensitive lists keys whose values are redacted in logs.
+	Sensitive []string
+	// MaxValueLen bounds a single value.
+	MaxValueLen int
+	// MaxTotalLen bounds the combined size of all propagated keys and
+	// values.
+	MaxTotalLen int
+}
+
+func (p *Policy) allowed(key string) bool {
+	return containsFold(p.Allow, key)
+}
+
+func (p *Policy) sensitive(key string) bool {
+	return containsFold(p.Sensitive, key)
+}
+
+func containsFold(keys []string, key string) bool {
+	return slices.ContainsFunc(keys, func(k string) bool { return strings.EqualFold(k, key) })
+}
+
+func (p *Policy) limits() (value, total int) {
+	value, total = p.MaxValueLen, p.MaxTotalLen
+	if value <= 0 {
+		value = DefaultMaxValueLen
+	}
+	if total <= 0 {
+		total = DefaultMaxTotalLen
+	}
+	return value, total
+}
+
+// filter returns the allowed pairs of md that fit the limits and are valid
+// in a header, in key order, and the allowed keys that were left out.
+func (p *Policy) filter(md MD) (kept []string, dropped []string) {
+	maxValue, maxTotal := p.limits()
+	keys := make([]string, 0, len(md))
+	for k := range md {
+		if p.allowed(k) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	total := 0
+	for _, k := range keys {
+		n := len(k) + len(md[k])
+		if len(md[k]) > maxValue || total+n > maxTotal || !validHeader(k, md[k]) {
+			dropped = append(dropped, k)
+			continue
+		}
+		total += n
+		kept = append(kept, k)
+	}
+	return kept, dropped
+}
+
+// Inject writes the allowed metadata of ctx to h. Keys that exceed the
+// size limits, or that net/http would refuse to send, are left out and
+// reported in a *LimitError; the others are still written.
+func (p *Policy) Inject(ctx context.Context, h http.Header) error {
+	md := fromContext(ctx)
+	kept, dropped := p.filter(md)
+	for _, k := range kept {
+		h.Set(HeaderPrefix+k, md[k])
+	}
+	if len(dropped) > 0 {
+		return &LimitError{Keys: dropped}
+	}
+	return nil
+}
+
+// Extract returns a copy of ctx carrying the allowed metadata found in h.
+// Headers are untrusted input, so the size limits and header checks apply
+// here too.
+func (p *Policy) Extract(ctx context.Context, h http.Header) context.Context {
+	md := make(MD)
+	for name, values := range h {
+		key, ok := strings.CutPrefix(strings.ToLower(name), strings.ToLower(HeaderPrefix))
+		if !ok || len(values) == 0 {
+			continue
+		}
+		md[key] = values[0]
+	}
+	kept, _ := p.filter(md)
+	if len(kept) == 0 {
+		return ctx
+	}
+	in := make(MD, len(kept))
+	for _, k := range kept {
+		in[k] = md[k]
+	}
+	return WithMD(ctx, in)
+}
+
+// Middleware extracts the allowed metadata of each request into its
+// context.
+func (p *Policy) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(p.Extract(r.Context(), r.Header)))
+	})
+}
+
+// Transport returns a RoundTripper that injects the allowed metadata of
+// each request's context before sending it with base. A nil base means
+// http.DefaultTransport. Keys over the size limits or invalid in headers are
+// silently left out.
+func (p *Policy) Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return roundTripper(func(r *http.Request) (*http.Response, error) {
+		r = r.Clone(r.Context())
+		for name := range r.Header {
+			if strings.HasPrefix(name, HeaderPrefix) {
+				r.Header.Del(name)
+			}
+		}
+		// Inject only fails with a *LimitError, and the request is still
+		// worth sending without the keys it left out.
+		p.Inject(r.Context(), r.Header)
+		return base.RoundTrip(r)
+	})
+}
+
+// validHeader reports whether key, with HeaderPrefix, is a valid header
+// name and v a valid header value, by the rules net/http enforces when
+// sending a request.
+func validHeader(key, v string) bool {
+	if key == "" {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if !isTokenChar(key[i]) {
+			return false
+		}
+	}
+	for i := 0; i < len(v); i++ {
+		if c := v[i]; c < ' ' && c != '\t' || c == 0x7f {
+			return false
+		}
+	}
+	return true
+}
+
+// isTokenChar reports whether c may appear in a header name (RFC 7230
+// token).
+func isTokenChar(c byte) bool {
+	switch {
+	case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		return true
+	}
+	return strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0
+}
+
+type roundTripper func(*http.Request) (*http.Response, error)
+
+func (f roundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+// LogAttr returns the metadata of ctx as a "metadata" group for log/slog,
+// with the values of sensitive keys replaced by Redacted. Unlike headers,
+// logs include keys that are not in Allow.
+func (p *Policy) LogAttr(ctx context.Context) slog.Attr {
+	md := fromContext(ctx)
+	keys := make([]string, 0, len(md))
+	for k := range md {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	attrs := make([]any, 0, len(keys))
+	for _, k := range keys {
+		v := md[k]
+		if p.sensitive(k) {
+			v = Redacted
+		}
+		attrs = append(attrs, slog.String(k, v))
+	}
+	return slog.Group("metadata", attrs...)
+}
@@ -0,0 +1,186 @@
+// Package parallel applies context-aware functions to slices and iterators
+// with bounded concurrency.
+//
+// By default the first error cancels the work still in flight and is
+// returned; with CollectErrors every item is processed and all errors are
+// returned together. Either way, no new item is started once the context
+// ends.
+package parallel
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// ItemError is the error returned for the item at Index.
+type ItemError struct {
+	Index int
+	Err   error
+}
+
+func (e *ItemError) Error() string {
+	return fmt.Sprintf("item %d: %v", e.Index, e.Err)
+}
+
+func (e *ItemError) Unwrap() error { return e.Err }
+
+// Errors collects the errors of several items, in item order.
+type Errors []*ItemError
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return "parallel: " + strings.Join(msgs, "; ")
+}
+
+func (e Errors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, err := range e {
+		errs[i] = err
+	}
+	return errs
+}
+
+type options struct {
+	collect bool
+	ordered bool
+}
+
+// Option configures Map, Filter, Reduce and MapSeq.
+type Option func(*options)
+
+// CollectErrors processes every item even when some fail, and returns all
+// failures as Errors.
+func CollectErrors() Option {
+	return func(o *options) { o.collect = true }
+}
+
+// Ordered makes MapSeq yield results in input order rather than completion
+// order. Slice functions always preserve order.
+func Ordered() Option {
+	return func(o *options) { o.ordered = true }
+}
+
+func apply(opts []Option) options {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+func workers(n int) int {
+	if n <= 0 {
+		return runtime.GOMAXPROCS(0)
+	}
+	return n
+}
+
+// Map calls fn on every item, at most n at a time, and returns the results
+// in item order. A non-positive n means GOMAXPROCS.
+//
+// The first failure cancels the other calls and is returned as an
+// *ItemError. With CollectErrors, Map returns every result together with
+// Errors for the items that failed, leaving their results zero. If ctx ends,
+// Map returns its cause.
+func Map[T, U any](ctx context.Context, items []T, n int, fn func(ctx context.Context, item T) (U, error), opts ...Option) ([]U, error) {
+	o := apply(opts)
+	parent := ctx
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	out := make([]U, len(items))
+	var (
+		mu   sync.Mutex
+		errs Errors
+		wg   sync.WaitGroup
+		sem  = make(chan struct{}, workers(n))
+	)
+loop:
+	for i, item := range items {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			break loop
+		}
+		// select picks at random when both cases are ready.
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			v, err := fn(ctx, item)
+			if err != nil {
+				e := &ItemError{Index: i, Err: err}
+				mu.Lock()
+				errs = append(errs, e)
+				mu.Unlock()
+				if !o.collect {
+					cancel(e)
+				}
+				return
+			}
+			out[i] = v
+		}()
+	}
+	wg.Wait()
+
+	if parent.Err() != nil {
+		return nil, context.Cause(parent)
+	}
+	if len(errs) == 0 {
+		return out, nil
+	}
+	if !o.collect {
+		return nil, context.Cause(ctx)
+	}
+	slices.SortFunc(errs, func(a, b *ItemError) int { return a.Index - b.Index })
+	return out, errs
+}
+
+// Filter returns the items for which pred reports true, in item order,
+// calling pred at most n at a time. Errors are handled as in Map.
+func Filter[T any](ctx context.Context, items []T, n int, pred func(ctx context.Context, item T) (bool, error), opts ...Option) ([]T, error) {
+	keep, err := Map(ctx, items, n, pred, opts...)
+	if keep == nil {
+		return nil, err
+	}
+	var out []T
+	for i, ok := range keep {
+		if ok {
+			out = append(out, items[i])
+		}
+	}
+	return out, err
+}
+
+// Reduce maps every item with fn, at most n at a time, then folds the
+// results into init with combine, in item order. Errors are handled as in
+// Map; with CollectErrors, failed items are left out of the fold.
+func Reduce[T, U, A any](ctx context.Context, items []T, n int, fn func(ctx context.Context, item T) (U, error), init A, combine func(acc A, v U) A, opts ...Option) (A, error) {
+	vals, err := Map(ctx, items, n, fn, opts...)
+	if vals == nil {
+		return init, err
+	}
+	failed := make(map[int]bool)
+	if errs, ok := err.(Errors); ok {
+		for _, e := range errs {
+			failed[e.Index] = true
+		}
+	}
+	acc := init
+	for i, v := range vals {
+		if !failed[i] {
+			acc = combine(acc, v)
+		}
+	}
+	return acc, err
+}
@@ -0,0 +1,240 @@
+package parallel
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func double(ctx context.Context, v int) (int, error) {
+	return v * 2, nil
+}
+
+// failOn returns a function that doubles items but fails on bad, and
+// records the cause of any call cancelled while sleeping for d.
+func failOn(bad int, d time.Duration, cancelled *atomic.Int32) func(context.Context, int) (int, error) {
+	return func(ctx context.Context, v int) (int, error) {
+		if v == bad {
+			return 0, errors.New("bad item")
+		}
+		select {
+		case <-time.After(d):
+			return v * 2, nil
+		case <-ctx.Done():
+			cancelled.Add(1)
+			return 0, context.Cause(ctx)
+		}
+	}
+}
+
+func TestMapKeepsOrder(t *testing.T) {
+	got, err := Map(context.Background(), []int{3, 1, 2}, 2, func(ctx context.Context, v int) (int, error) {
+		time.Sleep(time.Duration(v) * time.Millisecond)
+		return v * 2, nil
+	})
+	if err != nil || !slices.Equal(got, []int{6, 2, 4}) {
+		t.Errorf("Map = %v, %v, want [6 2 4]", got, err)
+	}
+}
+
+func TestMapBoundsConcurrency(t *testing.T) {
+	var cur, peak atomic.Int32
+	_, err := Map(context.Background(), make([]int, 20), 3, func(ctx context.Context, v int) (int, error) {
+		n := cur.Add(1)
+		defer cur.Add(-1)
+		for p := peak.Load(); n > p && !peak.CompareAndSwap(p, n); p = peak.Load() {
+		}
+		time.Sleep(time.Millisecond)
+		return v, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := peak.Load(); p > 3 {
+		t.Errorf("peak concurrency = %d, want at most 3", p)
+	}
+}
+
+func TestMapFirstErrorCancels(t *testing.T) {
+	var cancelled atomic.Int32
+	_, err := Map(context.Background(), []int{1, 2, 3}, 3, failOn(2, 5*time.Second, &cancelled))
+	var ie *ItemError
+	if !errors.As(err, &ie) || ie.Index != 1 {
+		t.Fatalf("Map = %v, want an ItemError for item 1", err)
+	}
+	if n := cancelled.Load(); n != 2 {
+		t.Errorf("cancelled calls = %d, want 2", n)
+	}
+}
+
+func TestMapCollectErrors(t *testing.T) {
+	var cancelled atomic.Int32
+	got, err := Map(context.Background(), []int{1, 2, 3}, 1, failOn(2, 0, &cancelled), CollectErrors())
+	var errs Errors
+	if !errors.As(err, &errs) || len(errs) != 1 || errs[0].Index != 1 {
+		t.Fatalf("Map = %v, want Errors for item 1", err)
+	}
+	if !slices.Equal(got, []int{2, 0, 6}) {
+		t.Errorf("results = %v, want [2 0 6]", got)
+	}
+}
+
+func TestMapParentCancelled(t *testing.T) {
+	stop := errors.New("stop")
+	ctx, cancel := context.WithCancelCause(context.Background())
+	cancel(stop)
+	var called atomic.Bool
+	_, err := Map(ctx, []int{1, 2}, 1, func(ctx context.Context, v int) (int, error) {
+		called.Store(true)
+		return v, nil
+	})
+	if !errors.Is(err, stop) {
+		t.Errorf("Map = %v, want the cause of ctx", err)
+	}
+	if called.Load() {
+		t.Error("item started after ctx ended")
+	}
+}
+
+func TestFilter(t *testing.T) {
+	got, err := Filter(context.Background(), []int{1, 2, 3, 4}, 2, func(ctx context.Context, v int) (bool, error) {
+		return v%2 == 0, nil
+	})
+	if err != nil || !slices.Equal(got, []int{2, 4}) {
+		t.Errorf("Filter = %v, %v, want [2 4]", got, err)
+	}
+}
+
+func TestReduce(t *testing.T) {
+	sum := func(acc, v int) int { return acc + v }
+	got, err := Reduce(context.Background(), []int{1, 2, 3}, 2, double, 10, sum)
+	if err != nil || got != 22 {
+		t.Errorf("Reduce = %d, %v, want 22", got, err)
+	}
+
+	var cancelled atomic.Int32
+	got, err = Reduce(context.Background(), []int{1, 2, 3}, 2, failOn(2, 0, &cancelled), 0, sum, CollectErrors())
+	if err == nil || got != 8 {
+		t.Errorf("Reduce = %d, %v, want 8 and an error", got, err)
+	}
+}
+
+func TestMapSeqOrdered(t *testing.T) {
+	var got []int
+	for v, err := range MapSeq(context.Background(), slices.Values([]int{3, 1, 2}), 3, func(ctx context.Context, v int) (int, error) {
+		time.Sleep(time.Duration(v) * time.Millisecond)
+		return v, nil
+	}, Ordered()) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, v)
+	}
+	if !slices.Equal(got, []int{3, 1, 2}) {
+		t.Errorf("MapSeq = %v, want input order", got)
+	}
+}
+
+func TestMapSeqCompletionOrder(t *testing.T) {
+	var got []int
+	for v, err := range MapSeq(context.Background(), slices.Values([]int{30, 1}), 2, func(ctx context.Context, v int) (int, error) {
+		time.Sleep(time.Duration(v) * time.Millisecond)
+		return v, nil
+	}) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, v)
+	}
+	if !slices.Equal(got, []int{1, 30}) {
+		t.Errorf("MapSeq = %v, want completion order", got)
+	}
+}
+
+func TestMapSeqErrorEndsIteration(t *testing.T) {
+	var cancelled atomic.Int32
+	var errs []error
+	for _, err := range MapSeq(context.Background(), slices.Values([]int{1, 2}), 1, failOn(1, 0, &cancelled)) {
+		errs = append(errs, err)
+	}
+	var ie *ItemError
+	if len(errs) != 1 || !errors.As(errs[0], &ie) || ie.Index != 0 {
+		t.Errorf("errors = %v, want one ItemError for item 0", errs)
+	}
+}
+
+func TestMapSeqBreakCancelsInFlight(t *testing.T) {
+	var cancelled atomic.Int32
+	fn := func(ctx context.Context, v int) (int, error) {
+		if v == 0 {
+			return v, nil
+		}
+		<-ctx.Done()
+		cancelled.Add(1)
+		return 0, context.Cause(ctx)
+	}
+	for range MapSeq(context.Background(), slices.Values([]int{0, 1, 2}), 3, fn) {
+		break
+	}
+	if n := cancelled.Load(); n != 2 {
+		t.Errorf("cancelled calls = %d, want 2 by the time the loop ends", n)
+	}
+}
+
+// TestMapSeqBreakWithBlockedSource checks that breaking out of the loop
+// does not wait for a source stuck waiting for its next item.
+func TestMapSeqBreakWithBlockedSource(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	seq := func(yield func(int) bool) {
+		if !yield(1) {
+			return
+		}
+		<-release
+		yield(2)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range MapSeq(context.Background(), seq, 2, double) {
+			break
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("breaking out of MapSeq waited for the source")
+	}
+}
+
+func TestMapSeqContextEndsWithBlockedSource(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	seq := func(yield func(int) bool) {
+		<-release
+	}
+	stop := errors.New("stop")
+	ctx, cancel := context.WithCancelCause(context.Background())
+	time.AfterFunc(10*time.Millisecond, func() { cancel(stop) })
+
+	errc := make(chan error, 1)
+	go func() {
+		var last error
+		for _, err := range MapSeq(ctx, seq, 2, double) {
+			last = err
+		}
+		errc <- last
+	}()
+	select {
+	case err := <-errc:
+		if !errors.Is(err, stop) {
+			t.Errorf("final error = %v, want the cause of ctx", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("MapSeq did not end with ctx")
+	}
+}
@@ -0,0 +1,135 @@
+package parallel
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"sync"
+)
+
+// errStopped cancels the work in flight when the consumer of MapSeq stops
+// ranging early.
+var errStopped = errors.New("parallel: iteration stopped")
+
+// MapSeq returns an iterator over fn applied to every item of seq, calling
+// fn at most n at a time. A non-positive n means GOMAXPROCS. Results are
+// yielded in completion order, or in input order with Ordered; either way
+// at most n items are in flight or waiting to be yielded.
+//
+// A failed item is yielded as a zero value with an *ItemError and, unless
+// CollectErrors is set, ends the iteration and cancels the rest. If ctx
+// ends, the iteration stops and yields its cause as the final error.
+// Breaking out of the loop cancels the calls in flight and waits for them
+// to return. It does not wait for seq: seq keeps running in the background
+// until it yields its next item or returns, so a seq that may block should
+// observe ctx too.
+func MapSeq[T, U any](ctx context.Context, seq iter.Seq[T], n int, fn func(ctx context.Context, item T) (U, error), opts ...Option) iter.Seq2[U, error] {
+	o := apply(opts)
+	return func(yield func(U, error) bool) {
+		ctx, cancel := context.WithCancelCause(ctx)
+		defer cancel(nil)
+
+		type result struct {
+			i   int
+			v   U
+			err error
+		}
+		results := make(chan result)
+		// A slot is taken before an item starts and released once its
+		// result has been yielded.
+		sem := make(chan struct{}, workers(n))
+
+		// Items are only started under mu while ctx is live, so that once
+		// stop has cancelled ctx, waiting on calls cannot miss one.
+		var (
+			mu    sync.Mutex
+			calls sync.WaitGroup
+		)
+		go func() {
+			defer func() {
+				calls.Wait()
+				close(results)
+			}()
+			i := 0
+			for item := range seq {
+				select {
+				case sem <- struct{}{}:
+				case <-ctx.Done():
+					return
+				}
+				mu.Lock()
+				if ctx.Err() != nil {
+					mu.Unlock()
+					return
+				}
+				calls.Add(1)
+				mu.Unlock()
+				go func(i int, item T) {
+					defer calls.Done()
+					v, err := fn(ctx, item)
+					select {
+					case results <- result{i: i, v: v, err: err}:
+					case <-ctx.Done():
+					}
+				}(i, item)
+				i++
+			}
+		}()
+
+		// stop cancels the calls in flight and waits for them, but not for
+		// seq, which may be blocked waiting for its next item.
+		stop := func() {
+			mu.Lock()
+			cancel(errStopped)
+			mu.Unlock()
+			calls.Wait()
+		}
+		var zero U
+		emit := func(r result) bool {
+			<-sem
+			if r.err == nil {
+				return yield(r.v, nil)
+			}
+			if !yield(zero, &ItemError{Index: r.i, Err: r.err}) {
+				return false
+			}
+			return o.collect
+		}
+
+		next := 0
+		pending := make(map[int]result)
+		for {
+			var r result
+			select {
+			case res, ok := <-results:
+				if !ok {
+					if ctx.Err() != nil {
+						yield(zero, context.Cause(ctx))
+					}
+					return
+				}
+				r = res
+			case <-ctx.Done():
+				stop()
+				yield(zero, context.Cause(ctx))
+				return
+			}
+			if !o.ordered {
+				if !emit(r) {
+					stop()
+					return
+				}
+				continue
+			}
+			pending[r.i] = r
+			for p, ok := pending[next]; ok; p, ok = pending[next] {
+				delete(pending, next)
+				next++
+				if !emit(p) {
+					stop()
+					return
+				}
+			}
+		}
+	}
+}
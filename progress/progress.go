@@ -0,0 +1,173 @@
+// Package progress reports the progress of long operations through the
+// context.
+//
+// An operation starts a Tracker with Start; nested functions started under
+// the returned context get child trackers, and every update is aggregated up
+// to the root. Watch, Stream and Bar consume the updates until a context
+// ends.
+package progress
+
+import (
+	"context"
+	"sync"
+)
+
+// Snapshot is the aggregated state of a Tracker and its children.
+type Snapshot struct {
+	Name    string `json:"name,omitempty"`
+	Done    int64  `json:"done"`
+	Total   int64  `json:"total"`
+	Message string `json:"message,omitempty"`
+}
+
+// Fraction returns Done/Total, clamped to [0, 1]. It is 0 while Total is
+// unknown.
+func (s Snapshot) Fraction() float64 {
+	if s.Total <= 0 {
+		return 0
+	}
+	return min(max(float64(s.Done)/float64(s.Total), 0), 1)
+}
+
+// tree is shared by every Tracker with the same root.
+type tree struct {
+	mu      sync.Mutex
+	seq     uint64
+	changed chan struct{}
+}
+
+// notify must be called with mu held.
+func (t *tree) notify() {
+	t.seq++
+	close(t.changed)
+	t.changed = make(chan struct{})
+}
+
+// Tracker records the progress of one operation.
+type Tracker struct {
+	tree     *tree
+	name     string
+	done     int64
+	total    int64
+	message  string
+	msgSeq   uint64
+	children []*Tracker
+}
+
+type trackerKey struct{}
+
+// Start begins tracking an operation expected to take total steps. If ctx
+// already carries a Tracker, the new one is its child and contributes to its
+// progress; otherwise it is a new root. The returned context carries the new
+// Tracker.
+func Start(ctx context.Context, name string, total int64) (context.Context, *Tracker) {
+	t := &Tracker{name: name, total: total}
+	if parent := FromContext(ctx); parent != nil {
+		t.tree = parent.tree
+		t.tree.mu.Lock()
+		parent.children = append(parent.children, t)
+		t.tree.notify()
+		t.tree.mu.Unlock()
+	} else {
+		t.tree = &tree{changed: make(chan struct{})}
+	}
+	return context.WithValue(ctx, trackerKey{}, t), t
+}
+
+// FromContext returns the Tracker carried by ctx, or nil.
+func FromContext(ctx context.Context) *Tracker {
+	t, _ := ctx.Value(trackerKey{}).(*Tracker)
+	return t
+}
+
+// Add records n more completed steps on the Tracker carried by ctx, if any.
+func Add(ctx context.Context, n int64) {
+	FromContext(ctx).Add(n)
+}
+
+// SetMessage sets the message of the Tracker carried by ctx, if any.
+func SetMessage(ctx context.Context, msg string) {
+	FromContext(ctx).SetMessage(msg)
+}
+
+// Add records n more completed steps. It is a no-op on a nil Tracker.
+func (t *Tracker) Add(n int64) {
+	t.update(func() { t.done += n })
+}
+
+// SetTotal changes the number of steps expected.
+func (t *Tracker) SetTotal(total int64) {
+	t.update(func() { t.total = total })
+}
+
+// SetMessage describes what the operation is doing now.
+func (t *Tracker) SetMessage(msg string) {
+	t.update(func() {
+		t.message = msg
+		t.msgSeq = t.tree.seq + 1
+	})
+}
+
+// Finish marks every expected step as done.
+func (t *Tracker) Finish() {
+	t.update(func() { t.done = t.total })
+}
+
+func (t *Tracker) update(fn func()) {
+	if t == nil {
+		return
+	}
+	t.tree.mu.Lock()
+	fn()
+	t.tree.notify()
+	t.tree.mu.Unlock()
+}
+
+// Snapshot returns the progress of t including all of its children. The
+// message is the one set most recently anywhere in the subtree.
+func (t *Tracker) Snapshot() Snapshot {
+	t.tree.mu.Lock()
+	defer t.tree.mu.Unlock()
+	s := Snapshot{Name: t.name}
+	var seq uint64
+	t.collect(&s, &seq)
+	return s
+}
+
+func (t *Tracker) collect(s *Snapshot, seq *uint64) {
+	s.Done += t.done
+	s.Total += t.total
+	if t.message != "" && t.msgSeq >= *seq {
+		s.Message, *seq = t.message, t.msgSeq
+	}
+	for _, c := range t.children {
+		c.collect(s, seq)
+	}
+}
+
+// Watch returns a channel that receives a Snapshot of t whenever it changes.
+// Updates that arrive faster than they are received are coalesced. The
+// channel is closed once ctx ends.
+func (t *Tracker) Watch(ctx context.Context) <-chan Snapshot {
+	ch := make(chan Snapshot)
+	go func() {
+		defer close(ch)
+		for {
+			t.tree.mu.Lock()
+			changed := t.tree.changed
+			t.tree.mu.Unlock()
+
+			select {
+			case ch <- t.Snapshot():
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case <-changed:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch
+}
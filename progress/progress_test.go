@@ -0,0 +1,142 @@
+package progress
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChildrenAddUpToRoot(t *testing.T) {
+	ctx, root := Start(context.Background(), "import", 10)
+	root.Add(2)
+
+	cctx, child := Start(ctx, "download", 20)
+	Add(cctx, 5)
+	SetMessage(cctx, "fetching page 3")
+
+	got := root.Snapshot()
+	want := Snapshot{Name: "import", Done: 7, Total: 30, Message: "fetching page 3"}
+	if got != want {
+		t.Errorf("Snapshot = %+v, want %+v", got, want)
+	}
+	if got := child.Snapshot(); got.Done != 5 || got.Total != 20 {
+		t.Errorf("child Snapshot = %+v, want only its own progress", got)
+	}
+}
+
+func TestLatestMessageWins(t *testing.T) {
+	ctx, root := Start(context.Background(), "job", 0)
+	_, a := Start(ctx, "a", 0)
+	_, b := Start(ctx, "b", 0)
+	b.SetMessage("from b")
+	a.SetMessage("from a")
+	if got := root.Snapshot().Message; got != "from a" {
+		t.Errorf("Message = %q, want the most recent one", got)
+	}
+}
+
+func TestFinish(t *testing.T) {
+	_, tr := Start(context.Background(), "job", 4)
+	tr.Add(1)
+	tr.Finish()
+	if got := tr.Snapshot().Fraction(); got != 1 {
+		t.Errorf("Fraction = %v, want 1", got)
+	}
+}
+
+func TestFraction(t *testing.T) {
+	tests := []struct {
+		s    Snapshot
+		want float64
+	}{
+		{Snapshot{Done: 1, Total: 4}, 0.25},
+		{Snapshot{Done: 5, Total: 4}, 1},
+		{Snapshot{Done: 3, Total: 0}, 0},
+	}
+	for _, tt := range tests {
+		if got := tt.s.Fraction(); got != tt.want {
+			t.Errorf("%+v.Fraction() = %v, want %v", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestNilTrackerIsNoop(t *testing.T) {
+	ctx := context.Background()
+	Add(ctx, 1)
+	SetMessage(ctx, "nothing tracks this")
+	if FromContext(ctx) != nil {
+		t.Error("FromContext returned a Tracker for a bare context")
+	}
+}
+
+func TestWatchDeliversLatestAndCloses(t *testing.T) {
+	_, tr := Start(context.Background(), "job", 3)
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := tr.Watch(ctx)
+
+	if s := <-ch; s.Done != 0 {
+		t.Fatalf("first snapshot = %+v, want the initial state", s)
+	}
+	tr.Add(1)
+	tr.Add(1)
+	deadline := time.After(5 * time.Second)
+	for done := false; !done; {
+		select {
+		case s := <-ch:
+			done = s.Done == 2
+		case <-deadline:
+			t.Fatal("no snapshot with both updates")
+		}
+	}
+
+	cancel()
+	for range ch {
+	}
+}
+
+func TestStream(t *testing.T) {
+	_, tr := Start(context.Background(), "job", 2)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Stream(context.Background(), w, r, tr)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if ct := res.Header.Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+
+	sc := bufio.NewScanner(res.Body)
+	tr.Add(2)
+	for sc.Scan() {
+		var s Snapshot
+		if err := json.Unmarshal(sc.Bytes(), &s); err != nil {
+			t.Fatal(err)
+		}
+		if s.Done == 2 {
+			// Hanging up ends the stream on the server side.
+			cancel()
+			return
+		}
+	}
+	t.Fatalf("stream ended before the update: %v", sc.Err())
+}
+
+func TestBar(t *testing.T) {
+	line := bar(Snapshot{Done: 1, Total: 2, Message: "halfway"}, 10)
+	if !strings.HasPrefix(line, "[#####     ]  50% halfway") {
+		t.Errorf("bar = %q", line)
+	}
+}
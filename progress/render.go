@@ -0,0 +1,56 @@
+package progress
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Stream writes every update of t to an HTTP response as a line of JSON,
+// flushing after each one, until ctx or the client's request ends.
+func Stream(ctx context.Context, w http.ResponseWriter, r *http.Request, t *Tracker) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stop := context.AfterFunc(r.Context(), cancel)
+	defer stop()
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Cache-Control", "no-cache")
+	rc := http.NewResponseController(w)
+	enc := json.NewEncoder(w)
+	for s := range t.Watch(ctx) {
+		if err := enc.Encode(s); err != nil {
+			return err
+		}
+		if err := rc.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Bar draws t as a single-line progress bar of the given width on w,
+// redrawing it on every update until ctx ends.
+func Bar(ctx context.Context, w io.Writer, t *Tracker, width int) {
+	var last string
+	for s := range t.Watch(ctx) {
+		last = bar(s, width)
+		fmt.Fprint(w, "\r"+last)
+	}
+	if last != "" {
+		fmt.Fprintln(w)
+	}
+}
+
+func bar(s Snapshot, width int) string {
+	filled := int(s.Fraction() * float64(width))
+	line := fmt.Sprintf("[%s%s] %3.0f%%", strings.Repeat("#", filled), strings.Repeat(" ", width-filled), s.Fraction()*100)
+	if s.Message != "" {
+		line += " " + s.Message
+	}
+	// Pad so a shorter message fully overwrites a longer one.
+	return fmt.Sprintf("%-*s", width+48, line)
+}
@@ -0,0 +1,160 @@
+// Package proxy implements a reverse proxy that propagates deadlines.
+//
+// The proxy reads the caller's time budget from TimeoutHeader, keeps a
+// margin of it for itself, and forwards the rest to the upstream. The
+// upstream request is cancelled as soon as the downstream client goes away
+// or the budget runs out, and every request is logged with the side that
+// ended it.
+package proxy
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"time"
+)
+
+// ErrBudgetExhausted is the cancellation cause of an upstream request whose
+// trimmed deadline passed.
+var ErrBudgetExhausted = errors.New("proxy: deadline budget exhausted")
+
+// Which side ended a request, as logged in the "ended_by" attribute.
+const (
+	EndedByUpstream = "upstream"
+	EndedByClient   = "client"
+	EndedByDeadline = "deadline"
+	EndedByError    = "upstream_error"
+)
+
+// Proxy forwards requests to a single upstream.
+type Proxy struct {
+	// Margin is subtracted from the incoming budget to leave the proxy time
+	// to write the response.
+	Margin time.Duration
+	// MaxTimeout bounds requests that arrive without a budget, and caps
+	// those that do. Zero means no cap.
+	MaxTimeout time.Duration
+	// Logger receives one record per request. Nil means slog.Default().
+	Logger *slog.Logger
+
+	rp *httputil.ReverseProxy
+}
+
+// New returns a Proxy forwarding to upstream through transport. A nil
+// transport means http.DefaultTransport.
+func New(upstream *url.URL, transport http.RoundTripper) *Proxy {
+	p := &Proxy{}
+	p.rp = &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(upstream)
+			pr.SetXForwarded()
+			pr.Out.Header.Del(TimeoutHeader)
+			SetTimeout(pr.Out.Header, pr.Out.Context())
+		},
+		Transport: transport,
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			if rec, ok := w.(*recorder); ok {
+				rec.err = err
+			}
+			if errors.Is(context.Cause(r.Context()), ErrBudgetExhausted) {
+				w.WriteHeader(http.StatusGatewayTimeout)
+				return
+			}
+			w.WriteHeader(http.StatusBadGateway)
+		},
+	}
+	return p
+}
+
+func (p *Proxy) logger() *slog.Logger {
+	if p.Logger != nil {
+		return p.Logger
+	}
+	return slog.Default()
+}
+
+// budget returns the time the upstream may use, or false if the request has
+// no deadline at all.
+func (p *Proxy) budget(r *http.Request) (time.Duration, bool) {
+	d, ok := Timeout(r.Header)
+	if dl, has := r.Context().Deadline(); has && (!ok || time.Until(dl) < d) {
+		d, ok = time.Until(dl), true
+	}
+	if p.MaxTimeout > 0 && (!ok || d > p.MaxTimeout) {
+		d, ok = p.MaxTimeout, true
+	}
+	if !ok {
+		return 0, false
+	}
+	return d - p.Margin, true
+}
+
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	rec := &recorder{ResponseWriter: w, status: http.StatusOK}
+
+	ctx := r.Context()
+	budget, limited := p.budget(r)
+	if limited {
+		if budget <= 0 {
+			rec.WriteHeader(http.StatusGatewayTimeout)
+			p.log(r, rec, start, budget, EndedByDeadline, ErrBudgetExhausted)
+			return
+		}
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, budget, ErrBudgetExhausted)
+		defer cancel()
+	}
+
+	p.rp.ServeHTTP(rec, r.WithContext(ctx))
+
+	endedBy, err := EndedByUpstream, error(nil)
+	switch {
+	case r.Context().Err() != nil:
+		// The downstream client disconnected; its request context is
+		// cancelled by net/http, and that cancelled ours.
+		endedBy, err = EndedByClient, context.Cause(r.Context())
+	case errors.Is(context.Cause(ctx), ErrBudgetExhausted):
+		endedBy, err = EndedByDeadline, ErrBudgetExhausted
+	case rec.err != nil:
+		endedBy, err = EndedByError, rec.err
+	}
+	p.log(r, rec, start, budget, endedBy, err)
+}
+
+func (p *Proxy) log(r *http.Request, rec *recorder, start time.Time, budget time.Duration, endedBy string, err error) {
+	attrs := []any{
+		slog.String("method", r.Method),
+		slog.String("path", r.URL.Path),
+		slog.Int("status", rec.status),
+		slog.Duration("elapsed", time.Since(start)),
+		slog.String("ended_by", endedBy),
+	}
+	if budget != 0 {
+		attrs = append(attrs, slog.Duration("budget", budget))
+	}
+	if err != nil {
+		attrs = append(attrs, slog.Any("error", err))
+	}
+	p.logger().InfoContext(r.Context(), "proxied request", attrs...)
+}
+
+// recorder remembers the status written to the downstream client.
+type recorder struct {
+	http.ResponseWriter
+	status int
+	// err is the upstream error reported to the ErrorHandler, if any.
+	err error
+}
+
+func (r *recorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *recorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
@@ -0,0 +1,208 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTimeoutHeader(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{"", 0, false},
+		{"250", 250 * time.Millisecond, true},
+		{"0", 0, true},
+		{"-5", 0, false},
+		{"soon", 0, false},
+		{"9223372036854775807", time.Duration(maxTimeoutMs) * time.Millisecond, true},
+		{"99999999999999999999999", time.Duration(maxTimeoutMs) * time.Millisecond, true},
+	}
+	for _, tt := range tests {
+		h := http.Header{}
+		if tt.value != "" {
+			h.Set(TimeoutHeader, tt.value)
+		}
+		got, ok := Timeout(h)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("Timeout(%q) = %v, %v, want %v, %v", tt.value, got, ok, tt.want, tt.ok)
+		}
+		if got < 0 {
+			t.Errorf("Timeout(%q) is negative", tt.value)
+		}
+	}
+}
+
+func TestSetTimeout(t *testing.T) {
+	h := http.Header{}
+	SetTimeout(h, context.Background())
+	if v := h.Get(TimeoutHeader); v != "" {
+		t.Errorf("header set without a deadline: %q", v)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	SetTimeout(h, ctx)
+	d, ok := Timeout(h)
+	if !ok || d <= 0 || d > time.Second {
+		t.Errorf("Timeout = %v, %v, want about a second", d, ok)
+	}
+}
+
+// logRecord is one JSON line written by the proxy's logger.
+type logRecord struct {
+	Status  int    `json:"status"`
+	EndedBy string `json:"ended_by"`
+}
+
+// newProxy starts upstream and returns a proxy in front of it together
+// with a function returning the last log record.
+func newProxy(t *testing.T, upstream http.HandlerFunc) (*Proxy, func() logRecord) {
+	t.Helper()
+	up := httptest.NewServer(upstream)
+	t.Cleanup(up.Close)
+	u, err := url.Parse(up.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	p := New(u, nil)
+	p.Logger = slog.New(slog.NewJSONHandler(&buf, nil))
+	last := func() logRecord {
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		var rec logRecord
+		if err := json.Unmarshal([]byte(lines[len(lines)-1]), &rec); err != nil {
+			t.Fatalf("log line: %v", err)
+		}
+		return rec
+	}
+	return p, last
+}
+
+func TestForwardsTrimmedBudget(t *testing.T) {
+	got := make(chan string, 1)
+	p, last := newProxy(t, func(w http.ResponseWriter, r *http.Request) {
+		got <- r.Header.Get(TimeoutHeader)
+		io.WriteString(w, "ok")
+	})
+	p.Margin = 100 * time.Millisecond
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(TimeoutHeader, "1000")
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d", w.Code)
+	}
+	d, ok := Timeout(http.Header{TimeoutHeader: {<-got}})
+	if !ok || d > 900*time.Millisecond || d < 500*time.Millisecond {
+		t.Errorf("upstream budget = %v, want just under 900ms", d)
+	}
+	if rec := last(); rec.EndedBy != EndedByUpstream {
+		t.Errorf("ended_by = %q, want %q", rec.EndedBy, EndedByUpstream)
+	}
+}
+
+func TestBudgetSmallerThanMargin(t *testing.T) {
+	p, last := newProxy(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Error("upstream called with no budget left")
+	})
+	p.Margin = 50 * time.Millisecond
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(TimeoutHeader, "10")
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, req)
+
+	if w.Code != http.StatusGatewayTimeout {
+		t.Errorf("status = %d, want 504", w.Code)
+	}
+	if rec := last(); rec.EndedBy != EndedByDeadline {
+		t.Errorf("ended_by = %q, want %q", rec.EndedBy, EndedByDeadline)
+	}
+}
+
+func TestHugeBudgetIsNotImmediate(t *testing.T) {
+	p, _ := newProxy(t, func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	})
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(TimeoutHeader, "9223372036854775807")
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("status = %d, want 200", w.Code)
+	}
+}
+
+func TestUpstreamTooSlow(t *testing.T) {
+	p, last := newProxy(t, func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	})
+	p.MaxTimeout = 50 * time.Millisecond
+
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if w.Code != http.StatusGatewayTimeout {
+		t.Errorf("status = %d, want 504", w.Code)
+	}
+	if rec := last(); rec.EndedBy != EndedByDeadline {
+		t.Errorf("ended_by = %q, want %q", rec.EndedBy, EndedByDeadline)
+	}
+}
+
+func TestClientGoesAway(t *testing.T) {
+	cancelled := make(chan struct{})
+	p, last := newProxy(t, func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		close(cancelled)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	req := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+	p.ServeHTTP(httptest.NewRecorder(), req)
+
+	select {
+	case <-cancelled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("upstream request not cancelled")
+	}
+	if rec := last(); rec.EndedBy != EndedByClient {
+		t.Errorf("ended_by = %q, want %q", rec.EndedBy, EndedByClient)
+	}
+}
+
+func TestUpstreamUnreachable(t *testing.T) {
+	up := httptest.NewServer(http.NotFoundHandler())
+	u, _ := url.Parse(up.URL)
+	up.Close()
+
+	var buf bytes.Buffer
+	p := New(u, nil)
+	p.Logger = slog.New(slog.NewJSONHandler(&buf, nil))
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if w.Code != http.StatusBadGateway {
+		t.Errorf("status = %d, want 502", w.Code)
+	}
+	if !strings.Contains(buf.String(), `"ended_by":"`+EndedByError+`"`) {
+		t.Errorf("log = %s, want ended_by %s", buf.String(), EndedByError)
+	}
+}
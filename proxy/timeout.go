@@ -0,0 +1,55 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// TimeoutHeader carries a caller's remaining time budget in milliseconds.
+// A relative budget is used rather than an absolute deadline so that clock
+// skew between hosts does not matter.
+const TimeoutHeader = "X-Request-Timeout"
+
+// SetTimeout records the time left until ctx's deadline in h. It does
+// nothing if ctx has no deadline.
+func SetTimeout(h http.Header, ctx context.Context) {
+	d, ok := ctx.Deadline()
+	if !ok {
+		return
+	}
+	ms := max(time.Until(d).Milliseconds(), 0)
+	h.Set(TimeoutHeader, strconv.FormatInt(ms, 10))
+}
+
+// maxTimeoutMs is the largest budget, in milliseconds, that fits in a
+// time.Duration.
+const maxTimeoutMs = math.MaxInt64 / int64(time.Millisecond)
+
+// Timeout returns the time budget recorded in h, if any. Budgets too large
+// for a time.Duration are clamped to the largest one.
+func Timeout(h http.Header) (time.Duration, bool) {
+	v := h.Get(TimeoutHeader)
+	if v == "" {
+		return 0, false
+	}
+	// Out of range values parse as the int64 limit and are clamped below.
+	ms, err := strconv.ParseInt(v, 10, 64)
+	if (err != nil && !errors.Is(err, strconv.ErrRange)) || ms < 0 {
+		return 0, false
+	}
+	ms = min(ms, maxTimeoutMs)
+	return time.Duration(ms) * time.Millisecond, true
+}
+
+// WithTimeout derives a context from r's context whose deadline is the
+// earlier of r's context deadline and the budget in r's TimeoutHeader.
+func WithTimeout(r *http.Request) (context.Context, context.CancelFunc) {
+	if d, ok := Timeout(r.Header); ok {
+		return context.WithTimeout(r.Context(), d)
+	}
+	return context.WithCancel(r.Context())
+}
@@ -0,0 +1,274 @@
+// Package pubsub is an in-memory event bus whose subscriptions live only as
+// long as the subscriber's context.
+//
+// Subscribe returns a Subscription whose channel is closed as soon as the
+// subscriber's context ends, so a handler that subscribes with its request
+// context cannot leak its subscription. Publishing to a subscriber that
+// falls behind either blocks the publisher, under the publisher's context,
+// or drops the event; a subscriber that stays behind for too long is
+// disconnected.
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// ErrClosed is returned by Publish after Close, and is the Err of the
+	// subscriptions Close ended.
+	ErrClosed = errors.New("pubsub: bus closed")
+	// ErrSlowSubscriber is the Err of a subscription that was disconnected
+	// for falling behind.
+	ErrSlowSubscriber = errors.New("pubsub: subscriber too slow")
+)
+
+// Policy decides what Publish does when a subscriber's buffer is full.
+type Policy int
+
+const (
+	// Block makes Publish wait for the subscriber, until the publisher's
+	// context ends.
+	Block Policy = iota
+	// Drop discards the event for that subscriber and moves on.
+	Drop
+)
+
+type options struct {
+	buffer int
+	policy Policy
+	slow   time.Duration
+}
+
+// Option configures a Bus.
+type Option func(*options)
+
+// Buffer sets the number of events buffered for each subscriber. The
+// default is 16.
+func Buffer(n int) Option {
+	return func(o *options) { o.buffer = max(n, 0) }
+}
+
+// WithPolicy sets what Publish does when a subscriber's buffer is full. The
+// default is Block.
+func WithPolicy(p Policy) Option {
+	return func(o *options) { o.policy = p }
+}
+
+// DisconnectSlow disconnects a subscriber once its buffer has been full for
+// d: with Block, when a single send has waited that long; with Drop, when
+// every event for that long has been dropped. By default slow subscribers
+// are never disconnected.
+func DisconnectSlow(d time.Duration) Option {
+	return func(o *options) { o.slow = d }
+}
+
+// Bus delivers events of type T to the subscribers of a topic. It is safe
+// for concurrent use.
+type Bus[T any] struct {
+	opts options
+
+	mu     sync.RWMutex
+	topics map[string]map[*Subscription[T]]struct{}
+	closed bool
+}
+
+// New returns a Bus with the given options.
+func New[T any](opts ...Option) *Bus[T] {
+	o := options{buffer: 16}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &Bus[T]{opts: o, topics: make(map[string]map[*Subscription[T]]struct{})}
+}
+
+// Subscription receives the events published to one topic.
+type Subscription[T any] struct {
+	// C delivers the events. It is closed when the subscription ends.
+	C <-chan T
+	// Topic is the topic subscribed to.
+	Topic string
+
+	ch      chan T
+	dropped atomic.Int64
+	// fullSince is when the buffer was first found full, in Unix
+	// nanoseconds, or 0 if the last send went through.
+	fullSince atomic.Int64
+
+	once sync.Once
+	done chan struct{}
+	err  error
+	// Publishers send under a read lock; end takes the write lock to
+	// close ch once they are gone.
+	mu sync.RWMutex
+}
+
+// Err returns why the subscription ended, or nil while it is active: the
+// cause of the subscriber's context, ErrSlowSubscriber or ErrClosed.
+func (s *Subscription[T]) Err() error {
+	select {
+	case <-s.done:
+		return s.err
+	default:
+		return nil
+	}
+}
+
+// Dropped returns the number of events dropped for the subscriber under
+// the Drop policy.
+func (s *Subscription[T]) Dropped() int64 {
+	return s.dropped.Load()
+}
+
+// end closes the subscription with err, if it is not already closed.
+func (s *Subscription[T]) end(err error) {
+	s.once.Do(func() {
+		s.err = err
+		// Closing done first releases the publishers blocked on s.
+		close(s.done)
+		s.mu.Lock()
+		close(s.ch)
+		s.mu.Unlock()
+	})
+}
+
+// Subscribe subscribes to topic until ctx ends, at which point the
+// subscription's channel is closed and Err returns the cause of ctx. If the
+// bus is closed, the returned subscription has already ended.
+func (b *Bus[T]) Subscribe(ctx context.Context, topic string) *Subscription[T] {
+	ch := make(chan T, b.opts.buffer)
+	s := &Subscription[T]{C: ch, Topic: topic, ch: ch, done: make(chan struct{})}
+
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		s.end(ErrClosed)
+		return s
+	}
+	subs := b.topics[topic]
+	if subs == nil {
+		subs = make(map[*Subscription[T]]struct{})
+		b.topics[topic] = subs
+	}
+	subs[s] = struct{}{}
+	b.mu.Unlock()
+
+	context.AfterFunc(ctx, func() { b.unsubscribe(s, context.Cause(ctx)) })
+	return s
+}
+
+func (b *Bus[T]) unsubscribe(s *Subscription[T], err error) {
+	b.mu.Lock()
+	if subs := b.topics[s.Topic]; subs != nil {
+		delete(subs, s)
+		if len(subs) == 0 {
+			delete(b.topics, s.Topic)
+		}
+	}
+	b.mu.Unlock()
+	s.end(err)
+}
+
+// Publish delivers v to every current subscriber of topic and returns how
+// many received it. Under the Block policy it returns the cause of ctx if
+// ctx ends while waiting for a subscriber; the subscribers already served
+// keep the event.
+func (b *Bus[T]) Publish(ctx context.Context, topic string, v T) (int, error) {
+	b.mu.RLock()
+	if b.closed {
+		b.mu.RUnlock()
+		return 0, ErrClosed
+	}
+	subs := make([]*Subscription[T], 0, len(b.topics[topic]))
+	for s := range b.topics[topic] {
+		subs = append(subs, s)
+	}
+	b.mu.RUnlock()
+
+	n := 0
+	for _, s := range subs {
+		ok, err := b.send(ctx, s, v)
+		if err != nil {
+			return n, err
+		}
+		if ok {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// send delivers v to s according to the bus policy, and disconnects s if it
+// has fallen behind for too long.
+func (b *Bus[T]) send(ctx context.Context, s *Subscription[T], v T) (bool, error) {
+	s.mu.RLock()
+	select {
+	case <-s.done:
+		s.mu.RUnlock()
+		return false, nil
+	case s.ch <- v:
+		s.mu.RUnlock()
+		if s.fullSince.Load() != 0 {
+			s.fullSince.Store(0)
+		}
+		return true, nil
+	default:
+	}
+
+	if b.opts.policy == Drop {
+		s.mu.RUnlock()
+		s.dropped.Add(1)
+		if b.opts.slow > 0 && s.markFull(b.opts.slow) {
+			b.unsubscribe(s, ErrSlowSubscriber)
+		}
+		return false, nil
+	}
+
+	var timeout <-chan time.Time
+	if b.opts.slow > 0 {
+		t := time.NewTimer(b.opts.slow)
+		defer t.Stop()
+		timeout = t.C
+	}
+	select {
+	case s.ch <- v:
+		s.mu.RUnlock()
+		return true, nil
+	case <-s.done:
+		s.mu.RUnlock()
+		return false, nil
+	case <-ctx.Done():
+		s.mu.RUnlock()
+		return false, context.Cause(ctx)
+	case <-timeout:
+		s.mu.RUnlock()
+		b.unsubscribe(s, ErrSlowSubscriber)
+		return false, nil
+	}
+}
+
+// markFull records that the subscriber's buffer is full and reports
+// whether it has been full for at least d.
+func (s *Subscription[T]) markFull(d time.Duration) bool {
+	now := time.Now().UnixNano()
+	s.fullSince.CompareAndSwap(0, now)
+	return time.Duration(now-s.fullSince.Load()) >= d
+}
+
+// Close ends every subscription with ErrClosed. Later calls to Publish
+// return ErrClosed.
+func (b *Bus[T]) Close() {
+	b.mu.Lock()
+	b.closed = true
+	topics := b.topics
+	b.topics = make(map[string]map[*Subscription[T]]struct{})
+	b.mu.Unlock()
+	for _, subs := range topics {
+		for s := range subs {
+			s.end(ErrClosed)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// waitClosed drains s.C until it is closed and returns what it received.
+func waitClosed[T any](t *testing.T, s *Subscription[T]) []T {
+	t.Helper()
+	var got []T
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case v, ok := <-s.C:
+			if !ok {
+				return got
+			}
+			got = append(got, v)
+		case <-timeout:
+			t.Fatal("subscription not closed")
+			return nil
+		}
+	}
+}
+
+func TestPublishDelivers(t *testing.T) {
+	b := New[int]()
+	ctx := context.Background()
+	s1 := b.Subscribe(ctx, "a")
+	s2 := b.Subscribe(ctx, "a")
+	other := b.Subscribe(ctx, "b")
+
+	n, err := b.Publish(ctx, "a", 7)
+	if n != 2 || err != nil {
+		t.Fatalf("Publish = %d, %v, want 2 subscribers", n, err)
+	}
+	for _, s := range []*Subscription[int]{s1, s2} {
+		if v := <-s.C; v != 7 {
+			t.Errorf("received %d, want 7", v)
+		}
+	}
+	select {
+	case v := <-other.C:
+		t.Errorf("subscriber of another topic received %d", v)
+	default:
+	}
+}
+
+func TestSubscriptionEndsWithContext(t *testing.T) {
+	b := New[int]()
+	stop := errors.New("stop")
+	ctx, cancel := context.WithCancelCause(context.Background())
+	s := b.Subscribe(ctx, "a")
+	if s.Err() != nil {
+		t.Fatalf("Err = %v before ctx ended", s.Err())
+	}
+
+	cancel(stop)
+	waitClosed(t, s)
+	if s.Err() != stop {
+		t.Errorf("Err = %v, want the cause of ctx", s.Err())
+	}
+	if n, err := b.Publish(context.Background(), "a", 1); n != 0 || err != nil {
+		t.Errorf("Publish = %d, %v, want no subscribers", n, err)
+	}
+}
+
+func TestBlockWaitsUntilPublisherContextEnds(t *testing.T) {
+	b := New[int](Buffer(0))
+	s := b.Subscribe(context.Background(), "a")
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if _, err := b.Publish(ctx, "a", 1); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Publish = %v, want the deadline", err)
+	}
+	if s.Err() != nil {
+		t.Errorf("subscription ended: %v", s.Err())
+	}
+}
+
+func TestBlockDeliversToSlowReader(t *testing.T) {
+	b := New[int](Buffer(0))
+	s := b.Subscribe(context.Background(), "a")
+	got := make(chan int)
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		got <- <-s.C
+	}()
+	if n, err := b.Publish(context.Background(), "a", 3); n != 1 || err != nil {
+		t.Fatalf("Publish = %d, %v, want 1", n, err)
+	}
+	if v := <-got; v != 3 {
+		t.Errorf("received %d, want 3", v)
+	}
+}
+
+func TestDropPolicy(t *testing.T) {
+	b := New[int](Buffer(1), WithPolicy(Drop))
+	s := b.Subscribe(context.Background(), "a")
+	for i, want := range []int{1, 0, 0} {
+		if n, err := b.Publish(context.Background(), "a", i); n != want || err != nil {
+			t.Errorf("Publish %d = %d, %v, want %d", i, n, err, want)
+		}
+	}
+	if s.Dropped() != 2 {
+		t.Errorf("Dropped = %d, want 2", s.Dropped())
+	}
+	if v := <-s.C; v != 0 {
+		t.Errorf("received %d, want the first event", v)
+	}
+}
+
+func TestDisconnectSlowBlock(t *testing.T) {
+	b := New[int](Buffer(0), DisconnectSlow(20*time.Millisecond))
+	s := b.Subscribe(context.Background(), "a")
+	if n, err := b.Publish(context.Background(), "a", 1); n != 0 || err != nil {
+		t.Fatalf("Publish = %d, %v, want 0 and no error", n, err)
+	}
+	waitClosed(t, s)
+	if s.Err() != ErrSlowSubscriber {
+		t.Errorf("Err = %v, want ErrSlowSubscriber", s.Err())
+	}
+}
+
+func TestDisconnectSlowDrop(t *testing.T) {
+	b := New[int](Buffer(1), WithPolicy(Drop), DisconnectSlow(20*time.Millisecond))
+	s := b.Subscribe(context.Background(), "a")
+	ctx := context.Background()
+	b.Publish(ctx, "a", 1)
+	b.Publish(ctx, "a", 2)
+	if s.Err() != nil {
+		t.Fatalf("disconnected after one dropped event: %v", s.Err())
+	}
+	time.Sleep(30 * time.Millisecond)
+	b.Publish(ctx, "a", 3)
+
+	if got := waitClosed(t, s); len(got) != 1 || got[0] != 1 {
+		t.Errorf("received %v, want [1]", got)
+	}
+	if s.Err() != ErrSlowSubscriber {
+		t.Errorf("Err = %v, want ErrSlowSubscriber", s.Err())
+	}
+}
+
+func TestClose(t *testing.T) {
+	b := New[int]()
+	s := b.Subscribe(context.Background(), "a")
+	b.Close()
+	waitClosed(t, s)
+	if s.Err() != ErrClosed {
+		t.Errorf("Err = %v, want ErrClosed", s.Err())
+	}
+	if _, err := b.Publish(context.Background(), "a", 1); err != ErrClosed {
+		t.Errorf("Publish = %v, want ErrClosed", err)
+	}
+	late := b.Subscribe(context.Background(), "a")
+	waitClosed(t, late)
+	if late.Err() != ErrClosed {
+		t.Errorf("Err after Close = %v, want ErrClosed", late.Err())
+	}
+}
+
+func TestCloseReleasesBlockedPublisher(t *testing.T) {
+	b := New[int](Buffer(0))
+	b.Subscribe(context.Background(), "a")
+	done := make(chan error, 1)
+	go func() {
+		_, err := b.Publish(context.Background(), "a", 1)
+		done <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	b.Close()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Publish = %v, want nil", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Publish still blocked after Close")
+	}
+}
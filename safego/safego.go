@@ -0,0 +1,91 @@
+// Package safego launches goroutines that cannot crash the process.
+//
+// A panic in a goroutine started with Go is recovered, turned into a
+// *PanicError carrying the stack trace, and used as the cancellation cause of
+// the group's context, so the goroutines sharing that context are told to
+// stop. The error is then returned by the group's Wait.
+package safego
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sync"
+)
+
+// PanicError is the error produced by a recovered panic.
+type PanicError struct {
+	Value any
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v\n\n%s", e.Value, e.Stack)
+}
+
+// Unwrap returns the panic value if it is an error.
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// Call runs fn in the calling goroutine and converts a panic into a
+// *PanicError.
+func Call(ctx context.Context, fn func(context.Context) error) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = &PanicError{Value: v, Stack: debug.Stack()}
+		}
+	}()
+	return fn(ctx)
+}
+
+type groupKey struct{}
+
+// Group is a set of goroutines started with Go that share a context.
+type Group struct {
+	cancel context.CancelCauseFunc
+	wg     sync.WaitGroup
+
+	once sync.Once
+	err  error
+}
+
+// WithContext returns a new Group and a context derived from ctx that carries
+// it. The context is cancelled the first time a goroutine in the group
+// returns an error or panics, with that error as the cause, or when Wait
+// returns.
+func WithContext(ctx context.Context) (*Group, context.Context) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	g := &Group{cancel: cancel}
+	return g, context.WithValue(ctx, groupKey{}, g)
+}
+
+// Go runs fn in a new goroutine belonging to the Group carried by ctx. A
+// panic in fn is recovered and treated as an error returned by fn.
+//
+// Go panics if ctx was not derived from a context returned by WithContext.
+func Go(ctx context.Context, fn func(ctx context.Context) error) {
+	g, ok := ctx.Value(groupKey{}).(*Group)
+	if !ok {
+		panic("safego: Go called with a context not derived from WithContext")
+	}
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		if err := Call(ctx, fn); err != nil {
+			g.once.Do(func() {
+				g.err = err
+				g.cancel(err)
+			})
+		}
+	}()
+}
+
+// Wait blocks until every goroutine in the group has returned, then returns
+// the first error or *PanicError, if any.
+func (g *Group) Wait() error {
+	g.wg.Wait()
+	g.cancel(context.Canceled)
+	return g.err
+}
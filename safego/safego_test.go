@@ -0,0 +1,93 @@
+package safego
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCallRecoversPanic(t *testing.T) {
+	err := Call(context.Background(), func(context.Context) error { panic("oops") })
+	var pe *PanicError
+	if !errors.As(err, &pe) {
+		t.Fatalf("Call = %v, want a *PanicError", err)
+	}
+	if pe.Value != "oops" {
+		t.Errorf("Value = %v, want oops", pe.Value)
+	}
+	if !strings.Contains(string(pe.Stack), "safego_test.go") {
+		t.Errorf("stack does not include the panicking function:\n%s", pe.Stack)
+	}
+}
+
+func TestCallReturnsError(t *testing.T) {
+	boom := errors.New("boom")
+	if err := Call(context.Background(), func(context.Context) error { return boom }); err != boom {
+		t.Errorf("Call = %v, want %v", err, boom)
+	}
+}
+
+func TestPanicErrorUnwrapsErrorValue(t *testing.T) {
+	boom := errors.New("boom")
+	err := Call(context.Background(), func(context.Context) error { panic(boom) })
+	if !errors.Is(err, boom) {
+		t.Errorf("Call = %v, want it to wrap the panic value", err)
+	}
+}
+
+func TestPanicCancelsGroup(t *testing.T) {
+	g, ctx := WithContext(context.Background())
+	causes := make(chan error, 1)
+	Go(ctx, func(ctx context.Context) error {
+		<-ctx.Done()
+		causes <- context.Cause(ctx)
+		return nil
+	})
+	Go(ctx, func(context.Context) error { panic("oops") })
+
+	err := g.Wait()
+	var pe *PanicError
+	if !errors.As(err, &pe) || pe.Value != "oops" {
+		t.Fatalf("Wait = %v, want the panic", err)
+	}
+	if cause := <-causes; !errors.As(cause, &pe) {
+		t.Errorf("sibling cause = %v, want the panic", cause)
+	}
+}
+
+func TestWaitReturnsFirstError(t *testing.T) {
+	g, ctx := WithContext(context.Background())
+	first := errors.New("first")
+	Go(ctx, func(context.Context) error { return first })
+	Go(ctx, func(ctx context.Context) error {
+		<-ctx.Done()
+		return errors.New("second")
+	})
+	if err := g.Wait(); err != first {
+		t.Errorf("Wait = %v, want %v", err, first)
+	}
+}
+
+func TestWaitCancelsContext(t *testing.T) {
+	g, ctx := WithContext(context.Background())
+	Go(ctx, func(context.Context) error { return nil })
+	if err := g.Wait(); err != nil {
+		t.Fatalf("Wait = %v", err)
+	}
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Error("context not cancelled after Wait")
+	}
+}
+
+func TestGoWithoutGroupPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Go did not panic without a group")
+		}
+	}()
+	Go(context.Background(), func(context.Context) error { return nil })
+}
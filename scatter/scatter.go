@@ -0,0 +1,197 @@
+// Package scatter fans a request out to several backends under a shared
+// deadline and gathers whatever comes back in time.
+//
+// Instead of failing when one backend is slow, Gather stops at a soft
+// deadline that falls before the hard one, cancels the stragglers, and
+// returns the results that completed along with what is missing and why.
+// The caller then decides whether the partial result is good enough.
+package scatter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrStraggler is the cancellation cause of calls still running at the soft
+// deadline, and the error recorded for them.
+var ErrStraggler = errors.New("scatter: not finished by the soft deadline")
+
+// Call is one backend call.
+type Call[T any] struct {
+	Name string
+	Fn   func(ctx context.Context) (T, error)
+}
+
+// Options sets the soft deadline. When both fields are set, the earlier
+// deadline wins; when neither applies, Gather waits for every call.
+type Options struct {
+	// SoftTimeout is how long to wait for calls before returning.
+	SoftTimeout time.Duration
+	// Margin places the soft deadline this long before ctx's deadline,
+	// leaving time to use the partial result.
+	Margin time.Duration
+}
+
+// Outcome is what happened to one call.
+type Outcome[T any] struct {
+	Name  string
+	Value T
+	// Err is the call's error, ErrStraggler if it was still running at the
+	// soft deadline, or the cause of ctx if that ended first.
+	Err error
+	// Done reports whether the call returned before Gather did.
+	Done    bool
+	Elapsed time.Duration
+}
+
+// Result holds the outcome of every call, in call order.
+type Result[T any] struct {
+	Outcomes []Outcome[T]
+}
+
+// Succeeded returns the calls that returned a value.
+func (r *Result[T]) Succeeded() []Outcome[T] {
+	return r.filter(func(o Outcome[T]) bool { return o.Done && o.Err == nil })
+}
+
+// Failed returns the calls that returned an error.
+func (r *Result[T]) Failed() []Outcome[T] {
+	return r.filter(func(o Outcome[T]) bool { return o.Done && o.Err != nil })
+}
+
+// Missing returns the calls that had not returned when Gather did.
+func (r *Result[T]) Missing() []Outcome[T] {
+	return r.filter(func(o Outcome[T]) bool { return !o.Done })
+}
+
+// Complete reports whether every call succeeded.
+func (r *Result[T]) Complete() bool {
+	return len(r.Succeeded()) == len(r.Outcomes)
+}
+
+// Require returns a *PartialError if fewer than n calls succeeded.
+func (r *Result[T]) Require(n int) error {
+	ok := r.Succeeded()
+	if len(ok) >= n {
+		return nil
+	}
+	e := &PartialError{Need: n, Got: len(ok)}
+	for _, o := range r.Outcomes {
+		if !o.Done || o.Err != nil {
+			e.Names = append(e.Names, o.Name)
+			e.Errs = append(e.Errs, o.Err)
+		}
+	}
+	return e
+}
+
+func (r *Result[T]) filter(keep func(Outcome[T]) bool) []Outcome[T] {
+	var out []Outcome[T]
+	for _, o := range r.Outcomes {
+		if keep(o) {
+			out = append(out, o)
+		}
+	}
+	return out
+}
+
+// PartialError reports that too few calls succeeded. Names and Errs list
+// the calls that did not, and why.
+type PartialError struct {
+	Need, Got int
+	Names     []string
+	Errs      []error
+}
+
+func (e *PartialError) Error() string {
+	parts := make([]string, len(e.Names))
+	for i, name := range e.Names {
+		parts[i] = fmt.Sprintf("%s: %v", name, e.Errs[i])
+	}
+	return fmt.Sprintf("scatter: %d of %d required calls succeeded (%s)", e.Got, e.Need, strings.Join(parts, "; "))
+}
+
+func (e *PartialError) Unwrap() []error { return e.Errs }
+
+// Gather runs every call concurrently and returns when all have returned,
+// the soft deadline passes, or ctx ends, whichever comes first. Calls still
+// running are cancelled with ErrStraggler, or with the cause of ctx.
+func Gather[T any](ctx context.Context, opts Options, calls ...Call[T]) *Result[T] {
+	start := time.Now()
+	soft, hasSoft := softDeadline(ctx, opts, start)
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(ErrStraggler)
+
+	type done struct {
+		i   int
+		v   T
+		err error
+		at  time.Time
+	}
+	results := make(chan done, len(calls))
+	for i, c := range calls {
+		go func() {
+			v, err := c.Fn(ctx)
+			results <- done{i: i, v: v, err: err, at: time.Now()}
+		}()
+	}
+
+	var timeout <-chan time.Time
+	if hasSoft {
+		t := time.NewTimer(time.Until(soft))
+		defer t.Stop()
+		timeout = t.C
+	}
+
+	res := &Result[T]{Outcomes: make([]Outcome[T], len(calls))}
+	for i, c := range calls {
+		res.Outcomes[i] = Outcome[T]{Name: c.Name, Err: ErrStraggler}
+	}
+	for range calls {
+		select {
+		case d := <-results:
+			res.Outcomes[d.i].Value = d.v
+			res.Outcomes[d.i].Err = d.err
+			res.Outcomes[d.i].Done = true
+			res.Outcomes[d.i].Elapsed = d.at.Sub(start)
+		case <-timeout:
+			return res.stop(start)
+		case <-ctx.Done():
+			cause := context.Cause(ctx)
+			for i := range res.Outcomes {
+				if !res.Outcomes[i].Done {
+					res.Outcomes[i].Err = cause
+				}
+			}
+			return res.stop(start)
+		}
+	}
+	return res
+}
+
+func (r *Result[T]) stop(start time.Time) *Result[T] {
+	elapsed := time.Since(start)
+	for i := range r.Outcomes {
+		if !r.Outcomes[i].Done {
+			r.Outcomes[i].Elapsed = elapsed
+		}
+	}
+	return r
+}
+
+func softDeadline(ctx context.Context, opts Options, start time.Time) (time.Time, bool) {
+	var soft time.Time
+	if opts.SoftTimeout > 0 {
+		soft = start.Add(opts.SoftTimeout)
+	}
+	if hard, ok := ctx.Deadline(); ok && opts.Margin > 0 {
+		if d := hard.Add(-opts.Margin); soft.IsZero() || d.Before(soft) {
+			soft = d
+		}
+	}
+	return soft, !soft.IsZero()
+}
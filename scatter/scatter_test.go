@@ -0,0 +1,150 @@
+package scatter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// backend returns a call that answers v after d, or fails with err if set,
+// and sends the cause on causes if cancelled first.
+func backend(name string, d time.Duration, v int, err error, causes chan<- error) Call[int] {
+	return Call[int]{Name: name, Fn: func(ctx context.Context) (int, error) {
+		select {
+		case <-time.After(d):
+			return v, err
+		case <-ctx.Done():
+			if causes != nil {
+				causes <- context.Cause(ctx)
+			}
+			return 0, context.Cause(ctx)
+		}
+	}}
+}
+
+func names[T any](os []Outcome[T]) []string {
+	var out []string
+	for _, o := range os {
+		out = append(out, o.Name)
+	}
+	return out
+}
+
+func TestGatherAll(t *testing.T) {
+	res := Gather(context.Background(), Options{},
+		backend("a", 10*time.Millisecond, 1, nil, nil),
+		backend("b", 0, 2, nil, nil),
+	)
+	if !res.Complete() {
+		t.Fatalf("outcomes = %+v, want all succeeded", res.Outcomes)
+	}
+	if res.Outcomes[0].Value != 1 || res.Outcomes[1].Value != 2 {
+		t.Errorf("values not in call order: %+v", res.Outcomes)
+	}
+	if res.Outcomes[0].Elapsed < 10*time.Millisecond {
+		t.Errorf("Elapsed = %v, want at least 10ms", res.Outcomes[0].Elapsed)
+	}
+}
+
+func TestSoftTimeoutCancelsStragglers(t *testing.T) {
+	causes := make(chan error, 1)
+	boom := errors.New("boom")
+	start := time.Now()
+	res := Gather(context.Background(), Options{SoftTimeout: 50 * time.Millisecond},
+		backend("fast", 0, 1, nil, nil),
+		backend("broken", 0, 0, boom, nil),
+		backend("slow", 5*time.Second, 3, nil, causes),
+	)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Gather took %v, want it to stop at the soft deadline", elapsed)
+	}
+
+	if got := names(res.Succeeded()); len(got) != 1 || got[0] != "fast" {
+		t.Errorf("Succeeded = %q", got)
+	}
+	if got := names(res.Failed()); len(got) != 1 || got[0] != "broken" {
+		t.Errorf("Failed = %q", got)
+	}
+	missing := res.Missing()
+	if len(missing) != 1 || missing[0].Name != "slow" || !errors.Is(missing[0].Err, ErrStraggler) {
+		t.Errorf("Missing = %+v, want slow as a straggler", missing)
+	}
+	if err := <-causes; !errors.Is(err, ErrStraggler) {
+		t.Errorf("straggler cause = %v, want ErrStraggler", err)
+	}
+	if res.Complete() {
+		t.Error("Complete = true with a missing call")
+	}
+}
+
+func TestMarginBeforeHardDeadline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	res := Gather(ctx, Options{Margin: 150 * time.Millisecond}, backend("slow", 5*time.Second, 1, nil, nil))
+	if elapsed := time.Since(start); elapsed > 150*time.Millisecond {
+		t.Errorf("Gather took %v, want it to return a margin before the deadline", elapsed)
+	}
+	if ctx.Err() != nil {
+		t.Error("hard deadline passed before Gather returned")
+	}
+	if o := res.Outcomes[0]; o.Done || !errors.Is(o.Err, ErrStraggler) {
+		t.Errorf("outcome = %+v, want a straggler", o)
+	}
+}
+
+func TestParentCancellation(t *testing.T) {
+	stop := errors.New("stop")
+	ctx, cancel := context.WithCancelCause(context.Background())
+	time.AfterFunc(10*time.Millisecond, func() { cancel(stop) })
+	res := Gather(ctx, Options{}, backend("slow", 5*time.Second, 1, nil, nil))
+	if o := res.Outcomes[0]; o.Done || !errors.Is(o.Err, stop) {
+		t.Errorf("outcome = %+v, want the cause of ctx", o)
+	}
+}
+
+func TestSoftDeadline(t *testing.T) {
+	start := time.Now()
+	hard, cancel := context.WithDeadline(context.Background(), start.Add(time.Second))
+	defer cancel()
+	tests := []struct {
+		name string
+		ctx  context.Context
+		opts Options
+		want time.Duration
+		ok   bool
+	}{
+		{"none", context.Background(), Options{}, 0, false},
+		{"margin without deadline", context.Background(), Options{Margin: time.Second}, 0, false},
+		{"timeout", context.Background(), Options{SoftTimeout: 100 * time.Millisecond}, 100 * time.Millisecond, true},
+		{"margin", hard, Options{Margin: 300 * time.Millisecond}, 700 * time.Millisecond, true},
+		{"earlier wins", hard, Options{SoftTimeout: 500 * time.Millisecond, Margin: 300 * time.Millisecond}, 500 * time.Millisecond, true},
+	}
+	for _, tt := range tests {
+		soft, ok := softDeadline(tt.ctx, tt.opts, start)
+		if ok != tt.ok || (ok && soft.Sub(start) != tt.want) {
+			t.Errorf("%s: soft deadline = %v, %v, want start+%v, %v", tt.name, soft.Sub(start), ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestRequire(t *testing.T) {
+	boom := errors.New("boom")
+	res := &Result[int]{Outcomes: []Outcome[int]{
+		{Name: "a", Value: 1, Done: true},
+		{Name: "b", Err: boom, Done: true},
+		{Name: "c", Err: ErrStraggler},
+	}}
+	if err := res.Require(1); err != nil {
+		t.Errorf("Require(1) = %v", err)
+	}
+	err := res.Require(2)
+	var pe *PartialError
+	if !errors.As(err, &pe) || pe.Need != 2 || pe.Got != 1 || len(pe.Names) != 2 {
+		t.Fatalf("Require(2) = %v, want a PartialError naming b and c", err)
+	}
+	if !errors.Is(err, boom) || !errors.Is(err, ErrStraggler) {
+		t.Errorf("Require(2) = %v, want it to wrap both reasons", err)
+	}
+}
@@ -0,0 +1,171 @@
+// Package scope ties the release of resources to the lifetime of a context.
+//
+// Cleanups registered with a Scope run in reverse order of registration,
+// like deferred calls, as soon as the scope's context ends or the scope is
+// closed early. Their errors, including recovered panics, are collected and
+// returned together by Close and Wait.
+package scope
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/surenraju-zz/go-context/safego"
+)
+
+type scopeKey struct{}
+
+// Scope is a stack of cleanups bound to a context.
+type Scope struct {
+	mu     sync.Mutex
+	fns    []func() error
+	closed bool
+	errs   []error
+	stop   func() bool
+	done   chan struct{}
+}
+
+// WithContext returns a new Scope and a context derived from ctx that
+// carries it. The scope's cleanups run when ctx ends, or earlier if Close is
+// called.
+func WithContext(ctx context.Context) (*Scope, context.Context) {
+	s := &Scope{done: make(chan struct{})}
+	// Hold the lock so that a ctx that has already ended cannot close the
+	// scope before stop is set.
+	s.mu.Lock()
+	s.stop = context.AfterFunc(ctx, func() { s.Close() })
+	s.mu.Unlock()
+	return s, context.WithValue(ctx, scopeKey{}, s)
+}
+
+// FromContext returns the Scope carried by ctx, if any.
+func FromContext(ctx context.Context) (*Scope, bool) {
+	s, ok := ctx.Value(scopeKey{}).(*Scope)
+	return s, ok
+}
+
+func mustScope(ctx context.Context) *Scope {
+	s, ok := FromContext(ctx)
+	if !ok {
+		panic("scope: context not derived from WithContext")
+	}
+	return s
+}
+
+// Defer registers fn to run when the scope ends. If the scope has already
+// ended, fn runs immediately and its error is added to those returned by
+// Close and Wait.
+func (s *Scope) Defer(fn func() error) {
+	s.mu.Lock()
+	if !s.closed {
+		s.fns = append(s.fns, fn)
+		s.mu.Unlock()
+		return
+	}
+	s.mu.Unlock()
+	if err := run(fn); err != nil {
+		s.mu.Lock()
+		s.errs = append(s.errs, err)
+		s.mu.Unlock()
+	}
+}
+
+// DeferClose registers c to be closed when the scope ends.
+func (s *Scope) DeferClose(c io.Closer) {
+	s.Defer(c.Close)
+}
+
+// TempFile creates a temporary file as os.CreateTemp does and registers it
+// to be closed and removed when the scope ends.
+func (s *Scope) TempFile(dir, pattern string) (*os.File, error) {
+	f, err := os.CreateTemp(dir, pattern)
+	if err != nil {
+		return nil, err
+	}
+	s.Defer(func() error {
+		return errors.Join(f.Close(), os.Remove(f.Name()))
+	})
+	return f, nil
+}
+
+// TempDir creates a temporary directory as os.MkdirTemp does and registers
+// it to be removed, with its contents, when the scope ends.
+func (s *Scope) TempDir(dir, pattern string) (string, error) {
+	name, err := os.MkdirTemp(dir, pattern)
+	if err != nil {
+		return "", err
+	}
+	s.Defer(func() error { return os.RemoveAll(name) })
+	return name, nil
+}
+
+// Close runs the registered cleanups now, most recent first, without
+// waiting for the context to end, and returns their errors joined. Later
+// calls wait for the first to finish and return the same errors.
+func (s *Scope) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return s.Wait()
+	}
+	s.closed = true
+	fns := s.fns
+	s.fns = nil
+	s.mu.Unlock()
+	s.stop()
+
+	var errs []error
+	for i := len(fns) - 1; i >= 0; i-- {
+		if err := run(fns[i]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	s.mu.Lock()
+	s.errs = append(errs, s.errs...)
+	s.mu.Unlock()
+	close(s.done)
+	return s.Wait()
+}
+
+// Done returns a channel that is closed once the cleanups have run.
+func (s *Scope) Done() <-chan struct{} {
+	return s.done
+}
+
+// Wait blocks until the cleanups have run, then returns their errors joined.
+func (s *Scope) Wait() error {
+	<-s.done
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return errors.Join(s.errs...)
+}
+
+// run calls a cleanup, turning a panic into a *safego.PanicError so that
+// the remaining cleanups still run.
+func run(fn func() error) error {
+	return safego.Call(context.Background(), func(context.Context) error { return fn() })
+}
+
+// Defer registers fn with the Scope carried by ctx. It panics if ctx was not
+// derived from a context returned by WithContext.
+func Defer(ctx context.Context, fn func() error) {
+	mustScope(ctx).Defer(fn)
+}
+
+// DeferClose registers c to be closed by the Scope carried by ctx.
+func DeferClose(ctx context.Context, c io.Closer) {
+	mustScope(ctx).DeferClose(c)
+}
+
+// TempFile creates a temporary file owned by the Scope carried by ctx.
+func TempFile(ctx context.Context, dir, pattern string) (*os.File, error) {
+	return mustScope(ctx).TempFile(dir, pattern)
+}
+
+// TempDir creates a temporary directory owned by the Scope carried by ctx.
+func TempDir(ctx context.Context, dir, pattern string) (string, error) {
+	return mustScope(ctx).TempDir(dir, pattern)
+}
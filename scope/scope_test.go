@@ -0,0 +1,152 @@
+package scope
+
+import (
+	"context"
+	"errors"
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/surenraju-zz/go-context/safego"
+)
+
+func waitDone(t *testing.T, s *Scope) {
+	t.Helper()
+	select {
+	case <-s.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("cleanups did not run")
+	}
+}
+
+func TestCleanupsRunInReverseWhenContextEnds(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	s, ctx := WithContext(ctx)
+	var order []int
+	for i := range 3 {
+		Defer(ctx, func() error { order = append(order, i); return nil })
+	}
+
+	cancel()
+	waitDone(t, s)
+	if !slices.Equal(order, []int{2, 1, 0}) {
+		t.Errorf("order = %v, want [2 1 0]", order)
+	}
+}
+
+func TestCloseJoinsErrorsAndRecoversPanics(t *testing.T) {
+	s, _ := WithContext(context.Background())
+	a, b := errors.New("a"), errors.New("b")
+	ran := false
+	s.Defer(func() error { ran = true; return a })
+	s.Defer(func() error { panic("oops") })
+	s.Defer(func() error { return b })
+
+	err := s.Close()
+	if !errors.Is(err, a) || !errors.Is(err, b) {
+		t.Errorf("Close = %v, want both errors", err)
+	}
+	var pe *safego.PanicError
+	if !errors.As(err, &pe) {
+		t.Errorf("Close = %v, want the panic", err)
+	}
+	if !ran {
+		t.Error("a cleanup after the panicking one did not run")
+	}
+	if err2 := s.Close(); err2 == nil || err2.Error() != err.Error() {
+		t.Errorf("second Close = %v, want the same errors", err2)
+	}
+}
+
+func TestDeferAfterCloseRunsImmediately(t *testing.T) {
+	s, _ := WithContext(context.Background())
+	s.Close()
+	late := errors.New("late")
+	ran := false
+	s.Defer(func() error { ran = true; return late })
+	if !ran {
+		t.Fatal("cleanup registered after Close did not run")
+	}
+	if err := s.Wait(); !errors.Is(err, late) {
+		t.Errorf("Wait = %v, want the late cleanup's error", err)
+	}
+}
+
+func TestCloseDetachesFromContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	s, _ := WithContext(ctx)
+	n := 0
+	s.Defer(func() error { n++; return nil })
+	s.Close()
+	cancel()
+	time.Sleep(10 * time.Millisecond)
+	if n != 1 {
+		t.Errorf("cleanup ran %d times, want once", n)
+	}
+}
+
+func TestAlreadyEndedContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	s, _ := WithContext(ctx)
+	waitDone(t, s)
+}
+
+func TestTempFileAndDir(t *testing.T) {
+	s, ctx := WithContext(context.Background())
+	f, err := TempFile(ctx, t.TempDir(), "scope-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := TempDir(ctx, t.TempDir(), "scope-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dir+"/x", []byte("x"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close = %v", err)
+	}
+	for _, name := range []string{f.Name(), dir} {
+		if _, err := os.Stat(name); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("%s still exists: %v", name, err)
+		}
+	}
+}
+
+type closer struct{ closed bool }
+
+func (c *closer) Close() error { c.closed = true; return nil }
+
+func TestDeferClose(t *testing.T) {
+	s, ctx := WithContext(context.Background())
+	var c closer
+	DeferClose(ctx, &c)
+	s.Close()
+	if !c.closed {
+		t.Error("Close not called")
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	if _, ok := FromContext(context.Background()); ok {
+		t.Error("FromContext found a scope in a bare context")
+	}
+	s, ctx := WithContext(context.Background())
+	defer s.Close()
+	if got, ok := FromContext(ctx); !ok || got != s {
+		t.Error("FromContext did not return the scope")
+	}
+}
+
+func TestDeferWithoutScopePanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Defer did not panic without a scope")
+		}
+	}()
+	Defer(context.Background(), func() error { return nil })
+}
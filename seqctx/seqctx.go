@@ -0,0 +1,119 @@
+// Package seqctx makes range-over-func iterators observe cancellation.
+//
+// Wrap and Wrap2 adapt any iter.Seq or iter.Seq2 so that a range loop over
+// them stops as soon as a context ends; Chan, Lines and Pages are
+// generators that do the same for channels, readers and paginated HTTP
+// APIs. After the loop, Err reports why the iteration ended.
+package seqctx
+
+import (
+	"context"
+	"iter"
+)
+
+// Iter is an iterator over values of type V that remembers why it ended.
+type Iter[V any] struct {
+	seq func(yield func(V) bool) error
+	err error
+}
+
+// All returns the iterator to range over. Ranging over it again starts a
+// new iteration and resets Err.
+func (it *Iter[V]) All() iter.Seq[V] {
+	return func(yield func(V) bool) {
+		it.err = nil
+		it.err = it.seq(yield)
+	}
+}
+
+// Err returns the error that ended the last iteration: the cause of the
+// context if it ended, or the error of the underlying source. It is nil if
+// the source was exhausted or the loop broke out early.
+func (it *Iter[V]) Err() error {
+	return it.err
+}
+
+// Iter2 is an iterator over pairs that remembers why it ended.
+type Iter2[K, V any] struct {
+	seq func(yield func(K, V) bool) error
+	err error
+}
+
+// All returns the iterator to range over, as for Iter.All.
+func (it *Iter2[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		it.err = nil
+		it.err = it.seq(yield)
+	}
+}
+
+// Err returns the error that ended the last iteration, as for Iter.Err.
+func (it *Iter2[K, V]) Err() error {
+	return it.err
+}
+
+// Wrap returns an Iter over seq that stops before yielding a value once ctx
+// has ended. seq itself cannot be interrupted: if it blocks producing a
+// value, the loop stops only once it does.
+func Wrap[V any](ctx context.Context, seq iter.Seq[V]) *Iter[V] {
+	return &Iter[V]{seq: func(yield func(V) bool) error {
+		var err error
+		if err = context.Cause(ctx); err != nil {
+			return err
+		}
+		for v := range seq {
+			if ctx.Err() != nil {
+				err = context.Cause(ctx)
+				break
+			}
+			if !yield(v) {
+				break
+			}
+		}
+		return err
+	}}
+}
+
+// Wrap2 is Wrap for iter.Seq2.
+func Wrap2[K, V any](ctx context.Context, seq iter.Seq2[K, V]) *Iter2[K, V] {
+	return &Iter2[K, V]{seq: func(yield func(K, V) bool) error {
+		var err error
+		if err = context.Cause(ctx); err != nil {
+			return err
+		}
+		for k, v := range seq {
+			if ctx.Err() != nil {
+				err = context.Cause(ctx)
+				break
+			}
+			if !yield(k, v) {
+				break
+			}
+		}
+		return err
+	}}
+}
+
+// Chan returns an Iter over the values received from ch until it is closed
+// or ctx ends. Unlike Wrap, it stops promptly even while waiting for a
+// value.
+func Chan[V any](ctx context.Context, ch <-chan V) *Iter[V] {
+	return &Iter[V]{seq: func(yield func(V) bool) error {
+		for {
+			select {
+			case v, ok := <-ch:
+				if !ok {
+					return nil
+				}
+				if ctx.Err() != nil {
+					return context.Cause(ctx)
+				}
+				if !yield(v) {
+					return nil
+				}
+			case <-ctx.Done():
+				return context.Cause(ctx)
+			}
+		}
+	}}
+}
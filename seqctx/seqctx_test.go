@@ -0,0 +1,238 @@
+package seqctx
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWrapStopsWhenContextEnds(t *testing.T) {
+	stop := errors.New("stop")
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+	it := Wrap(ctx, slices.Values([]int{1, 2, 3, 4}))
+
+	var got []int
+	for v := range it.All() {
+		got = append(got, v)
+		if v == 2 {
+			cancel(stop)
+		}
+	}
+	if !slices.Equal(got, []int{1, 2}) {
+		t.Errorf("got %v, want [1 2]", got)
+	}
+	if !errors.Is(it.Err(), stop) {
+		t.Errorf("Err = %v, want the cause of ctx", it.Err())
+	}
+}
+
+func TestWrapExhaustedAndBreak(t *testing.T) {
+	it := Wrap(context.Background(), slices.Values([]int{1, 2, 3}))
+	var got []int
+	for v := range it.All() {
+		got = append(got, v)
+	}
+	if !slices.Equal(got, []int{1, 2, 3}) || it.Err() != nil {
+		t.Errorf("got %v, %v, want every value and no error", got, it.Err())
+	}
+	for range it.All() {
+		break
+	}
+	if it.Err() != nil {
+		t.Errorf("Err after break = %v, want nil", it.Err())
+	}
+}
+
+func TestWrapEndedContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	it := Wrap(ctx, slices.Values([]int{1}))
+	for range it.All() {
+		t.Fatal("value yielded after ctx ended")
+	}
+	if !errors.Is(it.Err(), context.Canceled) {
+		t.Errorf("Err = %v, want context.Canceled", it.Err())
+	}
+}
+
+func TestAllResetsErr(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	it := Chan(ctx, make(chan int))
+	cancel()
+	for range it.All() {
+	}
+	if it.Err() == nil {
+		t.Fatal("Err = nil after ctx ended")
+	}
+
+	// A new iteration starts with a clean slate.
+	it.seq = func(func(int) bool) error { return nil }
+	for range it.All() {
+	}
+	if it.Err() != nil {
+		t.Errorf("Err = %v, want it reset by a new iteration", it.Err())
+	}
+}
+
+func TestWrap2(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	it := Wrap2(ctx, maps.All(map[string]int{"a": 1, "b": 2}))
+	n := 0
+	for range it.All() {
+		n++
+		cancel()
+	}
+	if n != 1 || !errors.Is(it.Err(), context.Canceled) {
+		t.Errorf("yielded %d pairs, Err = %v; want 1 and context.Canceled", n, it.Err())
+	}
+}
+
+func TestChanStopsWhileWaiting(t *testing.T) {
+	ch := make(chan int)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	it := Chan(ctx, ch)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range it.All() {
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Chan did not stop while waiting for a value")
+	}
+	if !errors.Is(it.Err(), context.DeadlineExceeded) {
+		t.Errorf("Err = %v, want the deadline", it.Err())
+	}
+}
+
+func TestChanClosed(t *testing.T) {
+	ch := make(chan int, 2)
+	ch <- 1
+	ch <- 2
+	close(ch)
+	it := Chan(context.Background(), ch)
+	var got []int
+	for v := range it.All() {
+		got = append(got, v)
+	}
+	if !slices.Equal(got, []int{1, 2}) || it.Err() != nil {
+		t.Errorf("got %v, %v", got, it.Err())
+	}
+}
+
+func TestLines(t *testing.T) {
+	it := Lines(context.Background(), strings.NewReader("a\nb\nc\n"))
+	var got []string
+	for line := range it.All() {
+		got = append(got, line)
+	}
+	if !slices.Equal(got, []string{"a", "b", "c"}) || it.Err() != nil {
+		t.Errorf("got %q, %v", got, it.Err())
+	}
+}
+
+func TestLinesClosesBlockedReader(t *testing.T) {
+	r, w := io.Pipe()
+	defer w.Close()
+	go io.WriteString(w, "first\n")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	it := Lines(ctx, r)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range it.All() {
+			// The next read blocks until the pipe is closed.
+			cancel()
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Lines did not stop a blocked read")
+	}
+	if !errors.Is(it.Err(), context.Canceled) {
+		t.Errorf("Err = %v, want context.Canceled", it.Err())
+	}
+}
+
+type page struct {
+	Items []int  `json:"items"`
+	Next  string `json:"next"`
+}
+
+func decodePage(res *http.Response) ([]int, string, error) {
+	var p page
+	err := json.NewDecoder(res.Body).Decode(&p)
+	return p.Items, p.Next, err
+}
+
+// pageServer serves three pages of two items each and counts requests.
+func pageServer(t *testing.T, requests *atomic.Int32) *httptest.Server {
+	t.Helper()
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		n, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		p := page{Items: []int{2 * n, 2*n + 1}}
+		if n < 2 {
+			p.Next = srv.URL + "/?page=" + strconv.Itoa(n+1)
+		}
+		json.NewEncoder(w).Encode(p)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestPages(t *testing.T) {
+	var requests atomic.Int32
+	srv := pageServer(t, &requests)
+	it := Pages(context.Background(), nil, srv.URL+"/?page=0", decodePage)
+	var got []int
+	for v := range it.All() {
+		got = append(got, v)
+	}
+	if !slices.Equal(got, []int{0, 1, 2, 3, 4, 5}) || it.Err() != nil {
+		t.Errorf("got %v, %v", got, it.Err())
+	}
+}
+
+func TestPagesAreLazy(t *testing.T) {
+	var requests atomic.Int32
+	srv := pageServer(t, &requests)
+	it := Pages(context.Background(), nil, srv.URL+"/?page=0", decodePage)
+	for v := range it.All() {
+		if v == 1 {
+			break
+		}
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("fetched %d pages, want 1", n)
+	}
+}
+
+func TestPagesHTTPError(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+	it := Pages(context.Background(), nil, srv.URL, decodePage)
+	for range it.All() {
+	}
+	if err := it.Err(); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("Err = %v, want the HTTP status", err)
+	}
+}
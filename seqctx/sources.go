@@ -0,0 +1,87 @@
+package seqctx
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Lines returns an Iter over the lines of r. If r is also an io.Closer, it
+// is closed when ctx ends during the iteration, so that a blocked read
+// returns; otherwise the loop stops after the read in progress completes.
+func Lines(ctx context.Context, r io.Reader) *Iter[string] {
+	return &Iter[string]{seq: func(yield func(string) bool) error {
+		if c, ok := r.(io.Closer); ok {
+			stop := context.AfterFunc(ctx, func() { c.Close() })
+			defer stop()
+		}
+		sc := bufio.NewScanner(r)
+		for sc.Scan() {
+			if ctx.Err() != nil {
+				return context.Cause(ctx)
+			}
+			if !yield(sc.Text()) {
+				return nil
+			}
+		}
+		if ctx.Err() != nil {
+			// The error from the closed reader is a consequence; report
+			// the reason instead.
+			return context.Cause(ctx)
+		}
+		return sc.Err()
+	}}
+}
+
+// PageFunc decodes one page of a paginated API, returning its items and the
+// URL of the next page, or "" on the last page.
+type PageFunc[T any] func(res *http.Response) (items []T, next string, err error)
+
+// Pages returns an Iter over the items of a paginated HTTP API, starting at
+// url. Each page is fetched with client, or http.DefaultClient if nil,
+// under ctx, so cancelling ctx also aborts the request in flight. Pages are
+// fetched lazily as the loop consumes items.
+func Pages[T any](ctx context.Context, client *http.Client, url string, decode PageFunc[T]) *Iter[T] {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &Iter[T]{seq: func(yield func(T) bool) error {
+		for next := url; next != ""; {
+			items, n, err := fetchPage(ctx, client, next, decode)
+			if err != nil {
+				if ctx.Err() != nil {
+					return context.Cause(ctx)
+				}
+				return err
+			}
+			for _, item := range items {
+				if ctx.Err() != nil {
+					return context.Cause(ctx)
+				}
+				if !yield(item) {
+					return nil
+				}
+			}
+			next = n
+		}
+		return nil
+	}}
+}
+
+func fetchPage[T any](ctx context.Context, client *http.Client, url string, decode PageFunc[T]) ([]T, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("seqctx: GET %s: %s", url, res.Status)
+	}
+	return decode(res)
+}
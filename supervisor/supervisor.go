@@ -0,0 +1,321 @@
+// Package supervisor restarts context-driven goroutines when they fail.
+//
+// A Supervisor runs a list of children, each a function that works until its
+// context is cancelled. When a child returns an error or panics, the
+// Supervisor restarts it, and possibly its siblings, according to its
+// Strategy, backing off between restarts. A Supervisor's Run method has the
+// same shape as a child's, so supervisors nest into trees.
+package supervisor
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/surenraju-zz/go-context/safego"
+)
+
+// Strategy decides which children restart when one of them fails.
+type Strategy int
+
+const (
+	// OneForOne restarts only the child that failed.
+	OneForOne Strategy = iota
+	// OneForAll stops every other child and restarts them all.
+	OneForAll
+	// RestForOne stops the children started after the failed one and
+	// restarts the failed child and those children.
+	RestForOne
+)
+
+// Restart decides whether a child that exited is started again.
+type Restart int
+
+const (
+	// Permanent children are always restarted.
+	Permanent Restart = iota
+	// Transient children are restarted only if they fail.
+	Transient
+	// Temporary children are never restarted.
+	Temporary
+)
+
+// Defaults used when the corresponding Supervisor field is zero.
+const (
+	DefaultMaxRestarts = 5
+	DefaultWindow      = 5 * time.Second
+	DefaultMinBackoff  = 100 * time.Millisecond
+	DefaultMaxBackoff  = 10 * time.Second
+)
+
+// Child is a goroutine managed by a Supervisor.
+type Child struct {
+	Name    string
+	Run     func(ctx context.Context) error
+	Restart Restart
+}
+
+// PanicError is the error reported for a child that panicked.
+type PanicError = safego.PanicError
+
+// SiblingError is the cancellation cause seen by a child that is stopped
+// because a sibling failed.
+type SiblingError struct {
+	Name string
+	Err  error
+}
+
+func (e *SiblingError) Error() string {
+	return fmt.Sprintf("supervisor: sibling %s failed: %v", e.Name, e.Err)
+}
+
+func (e *SiblingError) Unwrap() error { return e.Err }
+
+// RestartLimitError is returned by Run when children fail more often than
+// the Supervisor allows.
+type RestartLimitError struct {
+	Name     string
+	Restarts int
+	Window   time.Duration
+	Err      error
+}
+
+func (e *RestartLimitError) Error() string {
+	return fmt.Sprintf("supervisor: %d restarts within %v, last by %s: %v", e.Restarts, e.Window, e.Name, e.Err)
+}
+
+func (e *RestartLimitError) Unwrap() error { return e.Err }
+
+// Supervisor runs and restarts a set of children.
+type Supervisor struct {
+	Strategy Strategy
+	// MaxRestarts is the number of restarts tolerated within Window before
+	// Run gives up.
+	MaxRestarts int
+	Window      time.Duration
+	// MinBackoff and MaxBackoff bound the delay before a restart. The delay
+	// doubles with each consecutive failure of the same child.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// Report, if set, is called whenever a child exits with an error,
+	// including a *PanicError.
+	Report func(child string, err error)
+
+	children []Child
+}
+
+// New returns a Supervisor with the given strategy and children.
+func New(strategy Strategy, children ...Child) *Supervisor {
+	return &Supervisor{Strategy: strategy, children: children}
+}
+
+// Add registers another child. It must be called before Run.
+func (s *Supervisor) Add(c Child) {
+	s.children = append(s.children, c)
+}
+
+type exit struct {
+	idx int
+	gen int
+	err error
+}
+
+type child struct {
+	Child
+	gen      int
+	running  bool
+	cancel   context.CancelCauseFunc
+	started  time.Time
+	failures int
+}
+
+// Run starts the children and supervises them until ctx is cancelled, all
+// children have exited for good, or the restart limit is exceeded. When ctx
+// is cancelled every child is stopped and Run returns the cause of ctx.
+func (s *Supervisor) Run(ctx context.Context) error {
+	maxRestarts := orDefault(s.MaxRestarts, DefaultMaxRestarts)
+	window := orDefault(s.Window, DefaultWindow)
+
+	children := make([]*child, len(s.children))
+	for i, c := range s.children {
+		children[i] = &child{Child: c}
+	}
+	exits := make(chan exit)
+	restarts := make(chan []int)
+	quit := make(chan struct{})
+	defer close(quit)
+
+	start := func(i int) {
+		c := children[i]
+		c.gen++
+		c.running = true
+		c.started = time.Now()
+		cctx, cancel := context.WithCancelCause(ctx)
+		c.cancel = cancel
+		go func(gen int) {
+			err := safego.Call(cctx, c.Run)
+			cancel(nil)
+			exits <- exit{idx: i, gen: gen, err: err}
+		}(c.gen)
+	}
+
+	// pending holds exits received while waiting for other children to
+	// stop; they are handled before reading new ones.
+	var pending []exit
+	stop := func(idxs []int, cause error) {
+		waiting := make(map[int]bool)
+		for _, i := range idxs {
+			if children[i].running {
+				children[i].cancel(cause)
+				waiting[i] = true
+			}
+		}
+		for len(waiting) > 0 {
+			ev := <-exits
+			if waiting[ev.idx] && ev.gen == children[ev.idx].gen {
+				children[ev.idx].running = false
+				delete(waiting, ev.idx)
+				continue
+			}
+			pending = append(pending, ev)
+		}
+	}
+	all := func() []int {
+		idxs := make([]int, len(children))
+		for i := range idxs {
+			idxs[i] = i
+		}
+		return idxs
+	}
+	alive := func() bool {
+		for _, c := range children {
+			if c.running {
+				return true
+			}
+		}
+		return false
+	}
+
+	for i := range children {
+		start(i)
+	}
+
+	var history []time.Time
+	scheduled := 0
+	for {
+		if !alive() && scheduled == 0 && len(pending) == 0 {
+			return context.Cause(ctx)
+		}
+
+		var ev exit
+		if len(pending) > 0 {
+			ev, pending = pending[0], pending[1:]
+		} else {
+			select {
+			case <-ctx.Done():
+				stop(all(), context.Cause(ctx))
+				return context.Cause(ctx)
+			case idxs := <-restarts:
+				scheduled--
+				for _, i := range idxs {
+					if !children[i].running {
+						start(i)
+					}
+				}
+				continue
+			case ev = <-exits:
+			}
+		}
+
+		c := children[ev.idx]
+		if ev.gen != c.gen || !c.running {
+			continue
+		}
+		c.running = false
+		if ctx.Err() != nil {
+			continue
+		}
+		if ev.err != nil && s.Report != nil {
+			s.Report(c.Name, ev.err)
+		}
+		if !shouldRestart(c.Restart, ev.err) {
+			continue
+		}
+
+		now := time.Now()
+		history = append(history, now)
+		for len(history) > 0 && now.Sub(history[0]) > window {
+			history = history[1:]
+		}
+		if len(history) > maxRestarts {
+			err := &RestartLimitError{Name: c.Name, Restarts: len(history), Window: window, Err: ev.err}
+			stop(all(), err)
+			return err
+		}
+
+		if now.Sub(c.started) > window {
+			c.failures = 0
+		}
+		c.failures++
+		idxs := []int{ev.idx}
+		var siblings []int
+		switch s.Strategy {
+		case OneForAll:
+			for i := range children {
+				if i != ev.idx {
+					siblings = append(siblings, i)
+				}
+			}
+		case RestForOne:
+			for i := ev.idx + 1; i < len(children); i++ {
+				siblings = append(siblings, i)
+			}
+		}
+		if len(siblings) > 0 {
+			var stopped []int
+			for _, i := range siblings {
+				if children[i].running && children[i].Restart != Temporary {
+					stopped = append(stopped, i)
+				}
+			}
+			stop(siblings, &SiblingError{Name: c.Name, Err: ev.err})
+			idxs = append(idxs, stopped...)
+		}
+
+		scheduled++
+		delay := s.backoff(c.failures)
+		time.AfterFunc(delay, func() {
+			select {
+			case restarts <- idxs:
+			case <-quit:
+			}
+		})
+	}
+}
+
+func (s *Supervisor) backoff(failures int) time.Duration {
+	d := orDefault(s.MinBackoff, DefaultMinBackoff)
+	max := orDefault(s.MaxBackoff, DefaultMaxBackoff)
+	for i := 1; i < failures && d < max; i++ {
+		d *= 2
+	}
+	return min(d, max)
+}
+
+func shouldRestart(r Restart, err error) bool {
+	switch r {
+	case Permanent:
+		return true
+	case Transient:
+		return err != nil
+	default:
+		return false
+	}
+}
+
+func orDefault[T int | time.Duration](v, def T) T {
+	if v <= 0 {
+		return def
+	}
+	return v
+}
@@ -0,0 +1,208 @@
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fast keeps restarts quick so the tests do not wait on backoff.
+func fast(s *Supervisor) *Supervisor {
+	s.MinBackoff = time.Millisecond
+	s.MaxBackoff = time.Millisecond
+	return s
+}
+
+// counter counts the starts of a child.
+type counter struct{ n atomic.Int32 }
+
+// failOnce returns a child that fails on its first run and then blocks
+// until cancelled.
+func failOnce(c *counter, err error) func(context.Context) error {
+	return func(ctx context.Context) error {
+		if c.n.Add(1) == 1 {
+			return err
+		}
+		<-ctx.Done()
+		return nil
+	}
+}
+
+// block returns a child that runs until cancelled, recording the cause.
+func block(c *counter, causes chan<- error) func(context.Context) error {
+	return func(ctx context.Context) error {
+		c.n.Add(1)
+		<-ctx.Done()
+		if causes != nil {
+			causes <- context.Cause(ctx)
+		}
+		return nil
+	}
+}
+
+// runFor runs s for d and returns its error.
+func runFor(s *Supervisor, d time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+	return s.Run(ctx)
+}
+
+func TestOneForOneRestartsOnlyFailedChild(t *testing.T) {
+	var a, b counter
+	s := fast(New(OneForOne,
+		Child{Name: "a", Run: failOnce(&a, errors.New("boom"))},
+		Child{Name: "b", Run: block(&b, nil)},
+	))
+	if err := runFor(s, 100*time.Millisecond); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Run = %v, want the deadline", err)
+	}
+	if a.n.Load() != 2 || b.n.Load() != 1 {
+		t.Errorf("starts: a=%d b=%d, want a=2 b=1", a.n.Load(), b.n.Load())
+	}
+}
+
+func TestOneForAllRestartsSiblings(t *testing.T) {
+	var a, b counter
+	boom := errors.New("boom")
+	causes := make(chan error, 2)
+	s := fast(New(OneForAll,
+		Child{Name: "a", Run: failOnce(&a, boom)},
+		Child{Name: "b", Run: block(&b, causes)},
+	))
+	runFor(s, 100*time.Millisecond)
+	if a.n.Load() != 2 || b.n.Load() != 2 {
+		t.Errorf("starts: a=%d b=%d, want 2 each", a.n.Load(), b.n.Load())
+	}
+	var se *SiblingError
+	if err := <-causes; !errors.As(err, &se) || se.Name != "a" || !errors.Is(err, boom) {
+		t.Errorf("sibling cause = %v, want a SiblingError for a", err)
+	}
+}
+
+func TestRestForOneRestartsLaterChildren(t *testing.T) {
+	var first, failing, last counter
+	s := fast(New(RestForOne,
+		Child{Name: "first", Run: block(&first, nil)},
+		Child{Name: "failing", Run: failOnce(&failing, errors.New("boom"))},
+		Child{Name: "last", Run: block(&last, nil)},
+	))
+	runFor(s, 100*time.Millisecond)
+	if first.n.Load() != 1 || failing.n.Load() != 2 || last.n.Load() != 2 {
+		t.Errorf("starts: first=%d failing=%d last=%d, want 1, 2, 2", first.n.Load(), failing.n.Load(), last.n.Load())
+	}
+}
+
+func TestRestartLimit(t *testing.T) {
+	boom := errors.New("boom")
+	s := fast(New(OneForOne, Child{Name: "crashy", Run: func(context.Context) error { return boom }}))
+	s.MaxRestarts = 3
+	s.Window = time.Minute
+
+	err := runFor(s, 5*time.Second)
+	var rl *RestartLimitError
+	if !errors.As(err, &rl) || rl.Name != "crashy" || rl.Restarts != 4 || !errors.Is(err, boom) {
+		t.Errorf("Run = %v, want a RestartLimitError after 4 failures", err)
+	}
+}
+
+func TestPanicIsReported(t *testing.T) {
+	var mu sync.Mutex
+	var reported []error
+	var n counter
+	s := fast(New(OneForOne, Child{Name: "panicky", Run: func(ctx context.Context) error {
+		if n.n.Add(1) == 1 {
+			panic("oops")
+		}
+		<-ctx.Done()
+		return nil
+	}}))
+	s.Report = func(child string, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		reported = append(reported, err)
+	}
+	runFor(s, 100*time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	var pe *PanicError
+	if len(reported) != 1 || !errors.As(reported[0], &pe) || pe.Value != "oops" {
+		t.Errorf("reported %v, want one PanicError", reported)
+	}
+	if n.n.Load() != 2 {
+		t.Errorf("starts = %d, want the child restarted after the panic", n.n.Load())
+	}
+}
+
+func TestRestartPolicies(t *testing.T) {
+	boom := errors.New("boom")
+	tests := []struct {
+		name    string
+		restart Restart
+		err     error
+		starts  int32
+	}{
+		{"transient success", Transient, nil, 1},
+		{"transient failure", Transient, boom, 2},
+		{"temporary failure", Temporary, boom, 1},
+		{"permanent success", Permanent, nil, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var n counter
+			s := fast(New(OneForOne, Child{Name: "c", Restart: tt.restart, Run: func(ctx context.Context) error {
+				if n.n.Add(1) == 1 {
+					return tt.err
+				}
+				<-ctx.Done()
+				return nil
+			}}))
+			runFor(s, 50*time.Millisecond)
+			if got := n.n.Load(); got != tt.starts {
+				t.Errorf("starts = %d, want %d", got, tt.starts)
+			}
+		})
+	}
+}
+
+func TestRunReturnsWhenNothingLeft(t *testing.T) {
+	s := New(OneForOne, Child{Name: "once", Restart: Temporary, Run: func(context.Context) error { return nil }})
+	errc := make(chan error, 1)
+	go func() { errc <- s.Run(context.Background()) }()
+	select {
+	case err := <-errc:
+		if err != nil {
+			t.Errorf("Run = %v, want nil", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after its only child exited")
+	}
+}
+
+func TestCancelStopsChildren(t *testing.T) {
+	var n counter
+	causes := make(chan error, 1)
+	s := New(OneForOne, Child{Name: "c", Run: block(&n, causes)})
+	ctx, cancel := context.WithCancelCause(context.Background())
+	stop := errors.New("stop")
+	time.AfterFunc(20*time.Millisecond, func() { cancel(stop) })
+	if err := s.Run(ctx); !errors.Is(err, stop) {
+		t.Errorf("Run = %v, want the cause of ctx", err)
+	}
+	if err := <-causes; !errors.Is(err, stop) {
+		t.Errorf("child cause = %v, want the cause of ctx", err)
+	}
+}
+
+func TestBackoffDoubles(t *testing.T) {
+	s := &Supervisor{MinBackoff: 10 * time.Millisecond, MaxBackoff: 50 * time.Millisecond}
+	want := []time.Duration{10, 20, 40, 50, 50}
+	for i, w := range want {
+		if got := s.backoff(i + 1); got != w*time.Millisecond {
+			t.Errorf("backoff(%d) = %v, want %v", i+1, got, w*time.Millisecond)
+		}
+	}
+}
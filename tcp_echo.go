@@ -0,0 +1,41 @@
+//go:build ignore
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/surenraju-zz/go-context/tcpserver"
+)
+
+func main() {
+	// Create a TCP server that treats every line as a message
+	srv := &tcpserver.Server{
+		// Close connections that stay silent for 30 seconds
+		IdleTimeout: 30 * time.Second,
+		// No connection may stay open longer than 5 minutes
+		MaxConnDuration: 5 * time.Minute,
+		// Give each message at most 5 seconds
+		MessageTimeout: 5 * time.Second,
+		Handler: tcpserver.HandlerFunc(func(ctx context.Context, w io.Writer, msg []byte) error {
+			fmt.Fprintf(os.Stdout, "processing %q\n", msg)
+			select {
+			case <-time.After(2 * time.Second):
+				// We simulate some useful work, then echo the line back
+				_, err := w.Write(append(bytes.ToUpper(msg), '\n'))
+				return err
+			case <-ctx.Done():
+				// If the client hangs up (try Ctrl-C in `nc localhost 9000`)
+				// the context is cancelled and the cause tells us why
+				fmt.Fprintln(os.Stderr, "message cancelled:", context.Cause(ctx))
+				return context.Cause(ctx)
+			}
+		}),
+	}
+	fmt.Println(srv.ListenAndServe(":9000"))
+}
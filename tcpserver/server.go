@@ -0,0 +1,293 @@
+// Package tcpserver is a TCP server framework built around contexts.
+//
+// Each connection gets a context that is cancelled when the client hangs up,
+// when the connection has been idle too long, or when its absolute deadline
+// passes. Each message read from the connection is handled under a context
+// derived from it, so a handler stops as soon as nobody is left to read its
+// reply.
+package tcpserver
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// Causes of connection and message contexts ending.
+var (
+	ErrServerClosed   = errors.New("tcpserver: server closed")
+	ErrClientHangup   = errors.New("tcpserver: client hung up")
+	ErrIdleTimeout    = errors.New("tcpserver: connection idle")
+	ErrConnDeadline   = errors.New("tcpserver: connection deadline exceeded")
+	ErrMessageTimeout = errors.New("tcpserver: message deadline exceeded")
+)
+
+// A Handler handles one message read from a connection, writing any reply
+// to w. Returning an error closes the connection.
+type Handler interface {
+	ServeMessage(ctx context.Context, w io.Writer, msg []byte) error
+}
+
+// HandlerFunc adapts a function to a Handler.
+type HandlerFunc func(ctx context.Context, w io.Writer, msg []byte) error
+
+// ServeMessage calls f(ctx, w, msg).
+func (f HandlerFunc) ServeMessage(ctx context.Context, w io.Writer, msg []byte) error {
+	return f(ctx, w, msg)
+}
+
+// Server accepts connections and dispatches their messages to a Handler.
+type Server struct {
+	Handler Handler
+	// Split breaks the input stream into messages. Nil means
+	// bufio.ScanLines.
+	Split bufio.SplitFunc
+	// IdleTimeout closes a connection that sends no message for this long
+	// while no message is being handled. Zero means no limit.
+	IdleTimeout time.Duration
+	// MaxConnDuration closes a connection this long after it was accepted,
+	// whatever it is doing. Zero means no limit.
+	MaxConnDuration time.Duration
+	// MessageTimeout bounds the handling of each message. Zero means no
+	// limit.
+	MessageTimeout time.Duration
+
+	mu         sync.Mutex
+	listeners  map[net.Listener]struct{}
+	conns      map[*conn]struct{}
+	inShutdown bool
+	wg         sync.WaitGroup
+}
+
+// ListenAndServe listens on the TCP address addr and calls Serve.
+func (s *Server) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// Serve accepts connections on l until the server is shut down, and always
+// returns a non-nil error; after Shutdown it is ErrServerClosed.
+func (s *Server) Serve(l net.Listener) error {
+	s.mu.Lock()
+	if s.inShutdown {
+		s.mu.Unlock()
+		l.Close()
+		return ErrServerClosed
+	}
+	if s.listeners == nil {
+		s.listeners = make(map[net.Listener]struct{})
+		s.conns = make(map[*conn]struct{})
+	}
+	s.listeners[l] = struct{}{}
+	s.mu.Unlock()
+
+	for {
+		nc, err := l.Accept()
+		if err != nil {
+			s.mu.Lock()
+			delete(s.listeners, l)
+			closed := s.inShutdown
+			s.mu.Unlock()
+			if closed {
+				return ErrServerClosed
+			}
+			return err
+		}
+		c := s.newConn(nc)
+		s.mu.Lock()
+		if s.inShutdown {
+			s.mu.Unlock()
+			nc.Close()
+			continue
+		}
+		s.conns[c] = struct{}{}
+		s.wg.Add(1)
+		s.mu.Unlock()
+		go func() {
+			defer s.wg.Done()
+			c.serve()
+			s.mu.Lock()
+			delete(s.conns, c)
+			s.mu.Unlock()
+		}()
+	}
+}
+
+// Shutdown stops accepting connections and stops reading new messages,
+// then waits for the messages being handled to finish. If ctx ends first,
+// the remaining connections are cancelled with ErrServerClosed and closed,
+// and Shutdown returns the cause of ctx.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.inShutdown = true
+	for l := range s.listeners {
+		l.Close()
+	}
+	for c := range s.conns {
+		c.drain()
+	}
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		for c := range s.conns {
+			c.abort(ErrServerClosed)
+		}
+		s.mu.Unlock()
+		<-done
+		return context.Cause(ctx)
+	}
+}
+
+type conn struct {
+	srv    *Server
+	nc     net.Conn
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+	timer  *time.Timer
+
+	mu       sync.Mutex
+	busy     bool
+	draining bool
+	expires  time.Time
+}
+
+func (s *Server) newConn(nc net.Conn) *conn {
+	c := &conn{srv: s, nc: nc}
+	c.ctx, c.cancel = context.WithCancelCause(context.Background())
+	if s.MaxConnDuration > 0 {
+		c.expires = time.Now().Add(s.MaxConnDuration)
+		c.timer = time.AfterFunc(s.MaxConnDuration, func() { c.abort(ErrConnDeadline) })
+	}
+	return c
+}
+
+// setDeadline sets the read deadline for the connection's current state:
+// the absolute deadline while a message is being handled, the idle deadline
+// while waiting for one, and the past once draining.
+func (c *conn) setDeadline() {
+	var d time.Time
+	switch {
+	case c.draining:
+		d = time.Unix(1, 0)
+	case !c.busy && c.srv.IdleTimeout > 0:
+		d = time.Now().Add(c.srv.IdleTimeout)
+		if !c.expires.IsZero() && c.expires.Before(d) {
+			d = c.expires
+		}
+	default:
+		d = c.expires
+	}
+	c.nc.SetReadDeadline(d)
+}
+
+func (c *conn) setBusy(busy bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.busy = busy
+	c.setDeadline()
+}
+
+// drain stops reading further messages without cancelling the one being
+// handled.
+func (c *conn) drain() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.draining = true
+	c.setDeadline()
+}
+
+// abort cancels the connection's context and closes it.
+func (c *conn) abort(cause error) {
+	c.cancel(cause)
+	c.nc.Close()
+}
+
+func (c *conn) serve() {
+	defer func() {
+		if c.timer != nil {
+			c.timer.Stop()
+		}
+		c.abort(ErrServerClosed)
+	}()
+	msgs := make(chan []byte)
+	go c.read(msgs)
+
+	c.setBusy(false)
+	for msg := range msgs {
+		c.setBusy(true)
+		err := c.handle(msg)
+		c.setBusy(false)
+		if err != nil {
+			c.cancel(err)
+			return
+		}
+	}
+}
+
+func (c *conn) handle(msg []byte) error {
+	ctx := c.ctx
+	if t := c.srv.MessageTimeout; t > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, t, ErrMessageTimeout)
+		defer cancel()
+	}
+	if d, ok := ctx.Deadline(); ok {
+		c.nc.SetWriteDeadline(d)
+	}
+	return c.srv.Handler.ServeMessage(ctx, c.nc, msg)
+}
+
+// read delivers messages until the connection fails, cancelling the
+// connection's context with the reason unless the server is draining.
+func (c *conn) read(msgs chan<- []byte) {
+	defer close(msgs)
+	sc := bufio.NewScanner(c.nc)
+	if c.srv.Split != nil {
+		sc.Split(c.srv.Split)
+	}
+	for sc.Scan() {
+		msg := append([]byte(nil), sc.Bytes()...)
+		select {
+		case msgs <- msg:
+		case <-c.ctx.Done():
+			return
+		}
+	}
+
+	c.mu.Lock()
+	draining := c.draining
+	c.mu.Unlock()
+	if draining {
+		return
+	}
+	err := sc.Err()
+	switch {
+	case err == nil:
+		c.cancel(ErrClientHangup)
+	case errors.Is(err, os.ErrDeadlineExceeded):
+		if !c.expires.IsZero() && !time.Now().Before(c.expires) {
+			c.cancel(ErrConnDeadline)
+		} else {
+			c.cancel(ErrIdleTimeout)
+		}
+	default:
+		c.cancel(err)
+	}
+}
@@ -0,0 +1,225 @@
+package tcpserver
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// start serves s on a loopback listener and returns its address. The
+// server is shut down when the test ends.
+func start(t *testing.T, s *Server) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	errc := make(chan error, 1)
+	go func() { errc <- s.Serve(l) }()
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		s.Shutdown(ctx)
+		if err := <-errc; !errors.Is(err, ErrServerClosed) {
+			t.Errorf("Serve = %v, want ErrServerClosed", err)
+		}
+	})
+	return l.Addr().String()
+}
+
+func dial(t *testing.T, addr string) net.Conn {
+	t.Helper()
+	c, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+// causeHandler reports the cause of each message context once it ends.
+func causeHandler(causes chan<- error) HandlerFunc {
+	return func(ctx context.Context, w io.Writer, msg []byte) error {
+		<-ctx.Done()
+		causes <- context.Cause(ctx)
+		return nil
+	}
+}
+
+func waitCause(t *testing.T, causes <-chan error, want error) {
+	t.Helper()
+	select {
+	case err := <-causes:
+		if !errors.Is(err, want) {
+			t.Errorf("cause = %v, want %v", err, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("context not cancelled; want %v", want)
+	}
+}
+
+func TestEcho(t *testing.T) {
+	addr := start(t, &Server{Handler: HandlerFunc(func(ctx context.Context, w io.Writer, msg []byte) error {
+		_, err := fmt.Fprintf(w, "echo: %s\n", msg)
+		return err
+	})})
+	c := dial(t, addr)
+	r := bufio.NewReader(c)
+	for _, msg := range []string{"one", "two"} {
+		fmt.Fprintln(c, msg)
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := "echo: " + msg + "\n"; line != want {
+			t.Errorf("reply = %q, want %q", line, want)
+		}
+	}
+}
+
+func TestClientHangupCancelsHandler(t *testing.T) {
+	causes := make(chan error, 1)
+	addr := start(t, &Server{Handler: causeHandler(causes)})
+	c := dial(t, addr)
+	fmt.Fprintln(c, "slow")
+	time.Sleep(20 * time.Millisecond)
+	c.Close()
+	waitCause(t, causes, ErrClientHangup)
+}
+
+func TestMessageTimeout(t *testing.T) {
+	causes := make(chan error, 1)
+	addr := start(t, &Server{Handler: causeHandler(causes), MessageTimeout: 20 * time.Millisecond})
+	c := dial(t, addr)
+	fmt.Fprintln(c, "slow")
+	waitCause(t, causes, ErrMessageTimeout)
+}
+
+func TestMaxConnDuration(t *testing.T) {
+	causes := make(chan error, 1)
+	addr := start(t, &Server{Handler: causeHandler(causes), MaxConnDuration: 30 * time.Millisecond})
+	c := dial(t, addr)
+	fmt.Fprintln(c, "slow")
+	waitCause(t, causes, ErrConnDeadline)
+}
+
+func TestIdleTimeoutClosesConnection(t *testing.T) {
+	addr := start(t, &Server{
+		Handler:     HandlerFunc(func(context.Context, io.Writer, []byte) error { return nil }),
+		IdleTimeout: 20 * time.Millisecond,
+	})
+	c := dial(t, addr)
+	c.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := c.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("Read = %v, want EOF once the connection idles out", err)
+	}
+}
+
+func TestIdleTimeoutSparesBusyHandler(t *testing.T) {
+	done := make(chan error, 1)
+	addr := start(t, &Server{
+		Handler: HandlerFunc(func(ctx context.Context, w io.Writer, msg []byte) error {
+			select {
+			case <-time.After(60 * time.Millisecond):
+				done <- nil
+			case <-ctx.Done():
+				done <- context.Cause(ctx)
+			}
+			return nil
+		}),
+		IdleTimeout: 20 * time.Millisecond,
+	})
+	c := dial(t, addr)
+	fmt.Fprintln(c, "work")
+	if err := <-done; err != nil {
+		t.Errorf("handler cancelled while busy: %v", err)
+	}
+}
+
+func TestHandlerErrorClosesConnection(t *testing.T) {
+	addr := start(t, &Server{Handler: HandlerFunc(func(context.Context, io.Writer, []byte) error {
+		return errors.New("bad message")
+	})})
+	c := dial(t, addr)
+	fmt.Fprintln(c, "x")
+	c.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := c.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("Read = %v, want EOF", err)
+	}
+}
+
+func TestShutdownWaitsForHandlers(t *testing.T) {
+	started := make(chan struct{})
+	finished := make(chan error, 1)
+	s := &Server{Handler: HandlerFunc(func(ctx context.Context, w io.Writer, msg []byte) error {
+		close(started)
+		time.Sleep(50 * time.Millisecond)
+		finished <- ctx.Err()
+		return nil
+	})}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Serve(l)
+	c := dial(t, l.Addr().String())
+	fmt.Fprintln(c, "work")
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown = %v", err)
+	}
+	select {
+	case err := <-finished:
+		if err != nil {
+			t.Errorf("handler context ended early: %v", err)
+		}
+	default:
+		t.Error("Shutdown returned before the handler finished")
+	}
+}
+
+func TestShutdownTimeoutAbortsHandlers(t *testing.T) {
+	started := make(chan struct{})
+	causes := make(chan error, 1)
+	s := &Server{Handler: HandlerFunc(func(ctx context.Context, w io.Writer, msg []byte) error {
+		close(started)
+		<-ctx.Done()
+		causes <- context.Cause(ctx)
+		return nil
+	})}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Serve(l)
+	c := dial(t, l.Addr().String())
+	fmt.Fprintln(c, "stuck")
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := s.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Shutdown = %v, want the deadline", err)
+	}
+	waitCause(t, causes, ErrServerClosed)
+}
+
+func TestServeAfterShutdown(t *testing.T) {
+	s := &Server{}
+	s.Shutdown(context.Background())
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Serve(l); !errors.Is(err, ErrServerClosed) {
+		t.Errorf("Serve = %v, want ErrServerClosed", err)
+	}
+}
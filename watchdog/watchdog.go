@@ -0,0 +1,119 @@
+// Package watchdog cancels work that stops making progress.
+//
+// A deadline bounds the total time of an operation, which suits a request
+// but not a long job whose duration is unknown. A watchdog context instead
+// stays alive as long as the work keeps kicking it, and is cancelled with a
+// *StalledError once no kick has arrived for a whole idle window.
+package watchdog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrStalled matches every *StalledError with errors.Is.
+var ErrStalled = errors.New("watchdog: stalled")
+
+// StalledError is the cancellation cause of a watchdog context that was not
+// kicked in time.
+type StalledError struct {
+	Idle     time.Duration
+	LastKick time.Time
+	// Message is the message of the last kick, the last thing the work
+	// reported doing.
+	Message string
+}
+
+func (e *StalledError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("watchdog: no progress for %v", e.Idle)
+	}
+	return fmt.Sprintf("watchdog: no progress for %v since %q", e.Idle, e.Message)
+}
+
+func (e *StalledError) Unwrap() error { return ErrStalled }
+
+// Watchdog cancels its context when it is not kicked within its idle window.
+type Watchdog struct {
+	idle   time.Duration
+	cancel context.CancelCauseFunc
+
+	mu      sync.Mutex
+	timer   *time.Timer
+	last    time.Time
+	message string
+	stopped bool
+}
+
+type watchdogKey struct{}
+
+// New returns a context derived from ctx that is cancelled with a
+// *StalledError if the returned Watchdog is not kicked for idle. The first
+// window starts now. The context carries the Watchdog, so that code further
+// down can kick it with Kick.
+func New(ctx context.Context, idle time.Duration) (context.Context, *Watchdog) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	w := &Watchdog{idle: idle, cancel: cancel, last: time.Now()}
+	w.mu.Lock()
+	w.timer = time.AfterFunc(idle, w.fire)
+	w.mu.Unlock()
+	return context.WithValue(ctx, watchdogKey{}, w), w
+}
+
+// FromContext returns the Watchdog carried by ctx, or nil.
+func FromContext(ctx context.Context) *Watchdog {
+	w, _ := ctx.Value(watchdogKey{}).(*Watchdog)
+	return w
+}
+
+// Kick kicks the Watchdog carried by ctx, if any.
+func Kick(ctx context.Context, message string) {
+	if w := FromContext(ctx); w != nil {
+		w.Kick(message)
+	}
+}
+
+// Kick records progress and restarts the idle window. message describes
+// what the work is doing and ends up in the StalledError if the next kick
+// does not come in time; an empty message keeps the previous one.
+func (w *Watchdog) Kick(message string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.stopped {
+		return
+	}
+	w.last = time.Now()
+	if message != "" {
+		w.message = message
+	}
+	w.timer.Reset(w.idle)
+}
+
+// Stop releases the Watchdog and cancels its context with
+// context.Canceled. It should be called once the work is done.
+func (w *Watchdog) Stop() {
+	w.mu.Lock()
+	w.stopped = true
+	w.timer.Stop()
+	w.mu.Unlock()
+	w.cancel(context.Canceled)
+}
+
+func (w *Watchdog) fire() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.stopped {
+		return
+	}
+	// A kick may have reset the timer while this call was already on its
+	// way; if so, wait out the rest of the new window.
+	if idle := time.Since(w.last); idle < w.idle {
+		w.timer.Reset(w.idle - idle)
+		return
+	}
+	w.stopped = true
+	w.cancel(&StalledError{Idle: w.idle, LastKick: w.last, Message: w.message})
+}
@@ -0,0 +1,94 @@
+package watchdog
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func waitCause(t *testing.T, ctx context.Context) error {
+	t.Helper()
+	select {
+	case <-ctx.Done():
+		return context.Cause(ctx)
+	case <-time.After(5 * time.Second):
+		t.Fatal("watchdog context not cancelled")
+		return nil
+	}
+}
+
+func TestStallCancels(t *testing.T) {
+	ctx, w := New(context.Background(), 20*time.Millisecond)
+	defer w.Stop()
+	w.Kick("reading batch 7")
+
+	err := waitCause(t, ctx)
+	var se *StalledError
+	if !errors.As(err, &se) || !errors.Is(err, ErrStalled) {
+		t.Fatalf("cause = %v, want a StalledError", err)
+	}
+	if se.Message != "reading batch 7" || se.Idle != 20*time.Millisecond {
+		t.Errorf("StalledError = %+v", se)
+	}
+}
+
+func TestKicksKeepContextAlive(t *testing.T) {
+	ctx, w := New(context.Background(), 30*time.Millisecond)
+	defer w.Stop()
+	for range 10 {
+		time.Sleep(10 * time.Millisecond)
+		Kick(ctx, "")
+	}
+	if err := ctx.Err(); err != nil {
+		t.Fatalf("context ended despite kicks: %v", context.Cause(ctx))
+	}
+}
+
+func TestEmptyMessageKeepsPrevious(t *testing.T) {
+	ctx, w := New(context.Background(), 20*time.Millisecond)
+	defer w.Stop()
+	w.Kick("step 1")
+	w.Kick("")
+	var se *StalledError
+	if err := waitCause(t, ctx); !errors.As(err, &se) || se.Message != "step 1" {
+		t.Errorf("cause = %v, want the last non-empty message", err)
+	}
+}
+
+func TestStop(t *testing.T) {
+	ctx, w := New(context.Background(), 20*time.Millisecond)
+	w.Stop()
+	if err := waitCause(t, ctx); err != context.Canceled {
+		t.Errorf("cause = %v, want context.Canceled", err)
+	}
+	w.Kick("after stop")
+	time.Sleep(40 * time.Millisecond)
+	if err := context.Cause(ctx); err != context.Canceled {
+		t.Errorf("cause changed after Stop: %v", err)
+	}
+}
+
+func TestParentCancellation(t *testing.T) {
+	stop := errors.New("stop")
+	parent, cancel := context.WithCancelCause(context.Background())
+	ctx, w := New(parent, time.Hour)
+	defer w.Stop()
+	cancel(stop)
+	if err := waitCause(t, ctx); err != stop {
+		t.Errorf("cause = %v, want the parent's", err)
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	if FromContext(context.Background()) != nil {
+		t.Error("FromContext found a watchdog in a bare context")
+	}
+	Kick(context.Background(), "no watchdog here")
+
+	ctx, w := New(context.Background(), time.Hour)
+	defer w.Stop()
+	if FromContext(ctx) != w {
+		t.Error("FromContext did not return the watchdog")
+	}
+}